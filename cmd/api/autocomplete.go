@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	validator "greenlight.lazarmrkic.com/internal"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// "GET /v1/movies/autocomplete?q=" - prijedlozi naslova dok korisnik kuca
+// pošto se poziva na svaki pritisak tastera, rezultati se kratko čuvaju u "in-process" kešu,
+// a "rateLimit" middleware za ovu rutu koristi zaseban (veći) "budget"
+func (app *application) autocompleteMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	q := strings.TrimSpace(app.readString(qs, "q", ""))
+	limit := app.readInt(qs, "limit", 10, v)
+
+	v.Check(q != "", "q", "must be provided")
+	v.Check(utf8.RuneCountInString(q) <= 100, "q", "must not be more than 100 characters long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	key := fmt.Sprintf("%d:%s", limit, strings.ToLower(q))
+
+	suggestions, ok := app.autocompleteCache.Get(key)
+	if !ok {
+		var err error
+
+		suggestions, err = app.models.Movies.Autocomplete(q, limit)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.autocompleteCache.Set(key, suggestions)
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
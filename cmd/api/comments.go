@@ -0,0 +1,363 @@
+package main
+
+import (
+	"errors"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/http"
+)
+
+func (app *application) canModerateComments(r *http.Request) bool {
+	return app.contextGetPermissions(r).Include("comments:moderate")
+}
+
+// "GET /v1/movies/:id/comments" - niti komentara jednog filma, skupa sa odgovorima (najviše "data.CommentRepliesPerThread" po niti, uz "reply_count"), uz paginaciju
+// niti se podrazumijevano sortiraju od najnovije ("sort=created_at" za najstarije)
+func (app *application) listMovieCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "page_size", 20, v),
+		Sort:         app.readString(qs, "sort", "-created_at"),
+		SortSafeList: []string{"created_at", "-created_at"},
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, ok := app.readVisibleMovie(w, r)
+	if !ok {
+		return
+	}
+
+	comments, metadata, err := app.models.Comments.GetThreads(movie.ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"comments": comments, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "POST /v1/movies/:id/comments" - novi komentar: {"body": "...", "parent_id": 12}
+// "parent_id" je opcion i mora da pokazuje na komentar istog filma koji nije obrisan
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.readVisibleMovie(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Body     string `json:"body"`
+		ParentID *int64 `json:"parent_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	comment := &data.Comment{
+		MovieID:  movie.ID,
+		ParentID: input.ParentID,
+		UserID:   user.ID,
+		UserName: user.Name,
+		Body:     input.Body,
+	}
+
+	v := validator.New()
+
+	if input.ParentID != nil {
+		parent, err := app.models.Comments.Get(*input.ParentID)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		v.Check(err == nil && parent.MovieID == movie.ID && parent.DeletedAt == nil, "parent_id", "must reference an existing comment on this movie")
+	}
+
+	if data.ValidateComment(v, comment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Comments.Insert(comment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"comment": comment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "PATCH /v1/comments/:id" - izmjena teksta komentara: {"body": "..."}
+// komentar može da izmijeni samo njegov autor, a prethodni tekst se čuva u istoriji izmjena
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.readComment(w, r)
+	if !ok {
+		return
+	}
+
+	if comment.UserID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Body string `json:"body"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	comment.Body = input.Body
+
+	v := validator.New()
+
+	if data.ValidateComment(v, comment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Comments.Update(comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"comment": comment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "DELETE /v1/comments/:id" - brisanje komentara (autor ili moderator)
+// odgovori na obrisan komentar ostaju vidljivi
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.readComment(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	if comment.UserID != user.ID && !app.canModerateComments(r) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	err := app.models.Comments.Delete(comment.ID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "comment successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "GET /v1/comments/:id/revisions" - istorija izmjena komentara (autor ili moderator)
+func (app *application) listCommentRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.readComment(w, r)
+	if !ok {
+		return
+	}
+
+	if comment.UserID != app.contextGetUser(r).ID && !app.canModerateComments(r) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	revisions, err := app.models.Comments.GetRevisions(comment.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"revisions": revisions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "POST /v1/comments/:id/reports" - prijava komentara moderatorima: {"reason": "..."}
+func (app *application) reportCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.readComment(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	report := &data.CommentReport{
+		CommentID: comment.ID,
+		UserID:    app.contextGetUser(r).ID,
+		Reason:    input.Reason,
+	}
+
+	v := validator.New()
+
+	if data.ValidateCommentReport(v, report); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Comments.Report(report)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"report": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "GET /v1/moderation/comments" - red za moderaciju (komentari sa otvorenim prijavama), uz paginaciju
+func (app *application) listReportedCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters := data.Filters{
+		Page:     app.readInt(qs, "page", 1, v),
+		PageSize: app.readInt(qs, "page_size", 20, v),
+		// komentari sa najviše prijava su na vrhu reda
+		Sort:         "reports",
+		SortSafeList: []string{"reports"},
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	comments, metadata, err := app.models.Comments.GetReported(filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"comments": comments, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "POST /v1/moderation/comments/:id/resolve" - razrješenje prijava: {"resolution": "dismiss"} ili {"resolution": "remove"}
+func (app *application) resolveCommentReportsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Resolution string `json:"resolution"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(validator.PermittedValue(input.Resolution, data.CommentResolutions...), "resolution", "must be either dismiss or remove")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Comments.Resolve(id, app.contextGetUser(r).ID, input.Resolution)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "reports successfully resolved"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// učitavanje komentara iz ":id" parametra
+// obrisani komentari i komentari na filmove koje trenutni korisnik ne vidi se tretiraju kao da ne postoje
+// ukoliko komentar nije pronađen, odgovor je već poslat i vraća se "false"
+func (app *application) readComment(w http.ResponseWriter, r *http.Request) (*data.Comment, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	comment, err := app.models.Comments.Get(id)
+	if err == nil && comment.DeletedAt != nil {
+		err = data.ErrRecordNotFound
+	}
+
+	var movie *data.Movie
+	if err == nil {
+		movie, err = app.models.Movies.Get(comment.MovieID)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !app.movieVisible(r, movie) {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return comment, true
+}
@@ -12,6 +12,9 @@ type contextKey string
 // ona će nam služiti kao "key" za "getting"/"setting" informacija o korisniku unutar "request context"-a
 const userContextKey = contextKey("user")
 
+// "permission" kodovi korisnika, postavlja ih "requirePermission" middleware
+const permissionsContextKey = contextKey("permissions")
+
 // metoda "contextSetUser()" vraća novu kopiju "request"-a, skupa sa "User" struct-om proslijeđenim iz metode:
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
 	// kreiranje modifikovane kopije i dodavanje "User"-a u nju:
@@ -34,3 +37,19 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 
 	return user
 }
+
+func (app *application) contextSetPermissions(r *http.Request, permissions data.Permissions) *http.Request {
+	ctx := context.WithValue(r.Context(), permissionsContextKey, permissions)
+	return r.WithContext(ctx)
+}
+
+// vađenje "permission" kodova iz "request context"-a
+// dostupni su samo unutar ruta zaštićenih "requirePermission" middleware-om
+func (app *application) contextGetPermissions(r *http.Request) data.Permissions {
+	permissions, ok := r.Context().Value(permissionsContextKey).(data.Permissions)
+	if !ok {
+		panic("missing permissions value in request context")
+	}
+
+	return permissions
+}
@@ -63,6 +63,46 @@ func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+// ukoliko "ETag" iz "If-Match" header-a ne odgovara trenutnoj verziji resursa, šalje se "412 Precondition Failed":
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the resource has been modified since the version specified in the If-Match header"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+// ukoliko klijent pošalje tijelo zahtjeva u formatu koji "endpoint" ne podržava, šalje se "415 Unsupported Media Type":
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("the %q content type is not supported for this resource", r.Header.Get("Content-Type"))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+// "patch" dokument je ispravan, ali ne može da se primijeni na trenutno stanje resursa (recimo, "test" operacija nije prošla):
+func (app *application) patchConflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.errorResponse(w, r, http.StatusConflict, err.Error())
+}
+
+// "patch" dokument je sintaksno ispravan, ali nije moguće primijeniti ga (nepostojeća putanja, pogrešan tip vrijednosti,...):
+func (app *application) unprocessablePatchResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, err.Error())
+}
+
+// "atomic" uvoz sadrži više redova nego što može da se sačuva unutar jedne transakcije:
+func (app *application) importTooLargeResponse(w http.ResponseWriter, r *http.Request, limit int) {
+	message := fmt.Sprintf("atomic imports must not contain more than %d rows, use the best_effort mode for larger files", limit)
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, message)
+}
+
+// žanr ne može da se obriše dok ga koristi bar jedan film:
+func (app *application) genreInUseResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the genre is used by one or more movies and cannot be deleted"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+// akcija nije dozvoljena iz trenutnog statusa filma (recimo, "approve" nad filmom koji nije poslat na recenziju):
+func (app *application) invalidTransitionResponse(w http.ResponseWriter, r *http.Request, action, status string) {
+	message := fmt.Sprintf("the %s action is not allowed for a movie with the %s status", action, status)
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nakon koliko filmova se podaci "flush"-uju ka klijentu
+const exportFlushInterval = 100
+
+// "GET /v1/movies/export" - "streaming" izvoz čitavog kataloga (bez "page_size" ograničenja)
+// podržava iste filtere kao "GET /v1/movies" ("title", "genres", "year_min",...), a format se bira preko "format" parametra:
+// "json" (JSON niz, default), "ndjson" (jedan JSON objekat po redu) ili "csv" (isti format koji prihvata "POST /v1/movies/import")
+//
+// filmovi se ne učitavaju svi u memoriju - svaki film se upisuje u odgovor čim se pročita iz baze
+// pošto se "header"-i šalju prije prvog filma, greška koja se desi tokom izvoza može samo da se loguje (odgovor ostaje nekompletan)
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	taxonomy, err := app.genreTaxonomy()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	criteria := app.readMovieCriteria(qs, v, taxonomy)
+	if !app.canEditMovies(r) {
+		criteria.PublishedOnly = true
+	}
+	format := app.readString(qs, "format", "json")
+
+	v.Check(validator.PermittedValue(format, "json", "ndjson", "csv"), "format", "must be one of json, ndjson or csv")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	var (
+		write  func(movie *data.Movie) error
+		finish func() error
+	)
+
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="movies.csv"`)
+
+		err := cw.Write(movieCSVHeader)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		write = func(movie *data.Movie) error {
+			return cw.Write(movieCSVRecord(movie))
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case "ndjson":
+		enc := json.NewEncoder(w)
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+
+		// "Encode()" dodaje novi red nakon svakog objekta
+		write = func(movie *data.Movie) error {
+			return enc.Encode(movie)
+		}
+		finish = func() error {
+			return nil
+		}
+	default:
+		w.Header().Set("Content-Type", "application/json")
+
+		first := true
+		write = func(movie *data.Movie) error {
+			js, err := json.Marshal(movie)
+			if err != nil {
+				return err
+			}
+
+			prefix := ",\n"
+			if first {
+				prefix = "[\n"
+				first = false
+			}
+
+			_, err = fmt.Fprintf(w, "%s%s", prefix, js)
+			return err
+		}
+		finish = func() error {
+			closing := "\n]\n"
+			if first {
+				closing = "[]\n"
+			}
+
+			_, err := fmt.Fprint(w, closing)
+			return err
+		}
+	}
+
+	count := 0
+
+	err = rc.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Movies.Export(criteria, func(movie *data.Movie) error {
+		err := write(movie)
+		if err != nil {
+			return err
+		}
+
+		count++
+		if count%exportFlushInterval != 0 {
+			return nil
+		}
+
+		// "flush" šalje klijentu sve što je do sada upisano
+		// "WriteTimeout" servera bi prekinuo duži izvoz, pa se rok produžava nakon svakog uspješnog slanja
+		err = rc.Flush()
+		if err != nil {
+			return err
+		}
+
+		return rc.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	})
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		// ukoliko ništa nije poslato klijentu, još uvijek možemo da vratimo "500 Internal Server Error"
+		if count == 0 {
+			w.Header().Del("Content-Disposition")
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.logError(r, err)
+		return
+	}
+
+	err = rc.Flush()
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// kolone CSV izvoza - "POST /v1/movies/import" zanemaruje "id" i "version", pa izvezen fajl može ponovo da se uveze
+var movieCSVHeader = []string{"id", "title", "year", "runtime", "genres", "external_key", "version"}
+
+// "movieCSVRecord()" vraća red CSV izvoza za jedan film, u redoslijedu kolona iz "movieCSVHeader"
+func movieCSVRecord(movie *data.Movie) []string {
+	return []string{
+		strconv.FormatInt(movie.ID, 10),
+		movie.Title,
+		strconv.Itoa(int(movie.Year)),
+		strconv.Itoa(int(movie.Runtime)),
+		strings.Join(movie.Genres, "|"),
+		movie.ExternalKey,
+		strconv.Itoa(int(movie.Version)),
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"greenlight.lazarmrkic.com/internal/data"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestCSVExportCanBeImported(t *testing.T) {
+	movies := []*data.Movie{
+		{ID: 1, Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{"drama", "romance"}, ExternalKey: "casablanca", Version: 3},
+		{ID: 2, Title: "Black Panther, Part One", Year: 2018, Runtime: 134, Genres: []string{"action"}, Version: 1},
+	}
+
+	var buf bytes.Buffer
+
+	cw := csv.NewWriter(&buf)
+	if err := cw.Write(movieCSVHeader); err != nil {
+		t.Fatal(err)
+	}
+	for _, movie := range movies {
+		if err := cw.Write(movieCSVRecord(movie)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := newCSVMovieReader(&buf)
+	if err != nil {
+		t.Fatalf("exported header was rejected: %v", err)
+	}
+
+	for _, want := range movies {
+		got, rowErrors, err := reader.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rowErrors) > 0 {
+			t.Fatalf("row for %q has errors: %v", want.Title, rowErrors)
+		}
+
+		// "id" i "version" se ne uvoze
+		expected := data.Movie{Title: want.Title, Year: want.Year, Runtime: want.Runtime, Genres: want.Genres, ExternalKey: want.ExternalKey}
+		if !reflect.DeepEqual(*got, expected) {
+			t.Errorf("got %+v; want %+v", *got, expected)
+		}
+	}
+
+	if _, _, err := reader.Read(); !errors.Is(err, io.EOF) {
+		t.Errorf("got %v; want io.EOF", err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/url"
+)
+
+var (
+	// JSON ključevi filma koje klijent može da traži preko "fields" parametra ("sparse fieldsets")
+	movieFieldSafeList = []string{"id", "title", "year", "runtime", "genres", "external_key", "external_ids", "version", "status", "publish_at", "unpublish_at", "relevance", "highlight", "popularity", "localized_title"}
+	// povezani resursi koji mogu da se ugrade u odgovor preko "include" parametra
+	movieIncludeSafeList = []string{"ratings", "titles", "releases", "external_ids"}
+)
+
+// učitavanje i validacija "fields" i "include" parametara (recimo "?fields=id,title&include=ratings")
+func (app *application) readMovieFieldsets(qs url.Values, v *validator.Validator) (fields []string, includes []string) {
+	fields = app.readCSV(qs, "fields", []string{})
+	includes = app.readCSV(qs, "include", []string{})
+
+	for _, field := range fields {
+		v.Check(validator.PermittedValue(field, movieFieldSafeList...), "fields", "contains an unknown field: "+field)
+	}
+	for _, include := range includes {
+		v.Check(validator.PermittedValue(include, movieIncludeSafeList...), "include", "contains an unknown resource: "+include)
+	}
+
+	return fields, includes
+}
+
+// "shapeMovies()" prilagođava JSON reprezentaciju filmova "fields" i "include" parametrima
+// ukoliko nijedan parametar nije poslat, filmovi se vraćaju nepromijenjeni
+//
+// svaki film se prvo enkodira u JSON (kako bi se ispoštovali postojeći "struct" tagovi i "Runtime" format),
+// nakon toga se zadržavaju samo traženi ključevi i dodaju se ugrađeni resursi
+func (app *application) shapeMovies(movies []*data.Movie, fields []string, includes []string) (any, error) {
+	if len(fields) == 0 && len(includes) == 0 {
+		return movies, nil
+	}
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	// ugrađeni resursi se učitavaju jednim upitom za sve filmove, a ne upitom po filmu
+	var ratings map[int64]data.RatingSummary
+	if validator.PermittedValue("ratings", includes...) {
+		var err error
+		ratings, err = app.models.Ratings.GetSummaries(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var titles map[int64][]data.MovieTitle
+	if validator.PermittedValue("titles", includes...) {
+		var err error
+		titles, err = app.models.Localizations.GetTitles(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var releases map[int64][]data.MovieRelease
+	if validator.PermittedValue("releases", includes...) {
+		var err error
+		releases, err = app.models.Localizations.GetReleases(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// lista filmova ne učitava spoljne identifikatore, pa se oni ugrađuju tek na zahtjev
+	if validator.PermittedValue("external_ids", includes...) {
+		externalIDs, err := app.models.Movies.GetExternalIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, movie := range movies {
+			movie.ExternalIDs = externalIDs[movie.ID]
+		}
+	}
+
+	shaped := make([]map[string]any, len(movies))
+
+	for i, movie := range movies {
+		js, err := json.Marshal(movie)
+		if err != nil {
+			return nil, err
+		}
+
+		var all map[string]json.RawMessage
+		err = json.Unmarshal(js, &all)
+		if err != nil {
+			return nil, err
+		}
+
+		shaped[i] = make(map[string]any)
+		for key, value := range all {
+			if len(fields) == 0 || validator.PermittedValue(key, fields...) {
+				shaped[i][key] = value
+			}
+		}
+
+		if ratings != nil {
+			shaped[i]["ratings"] = ratings[movie.ID]
+		}
+		if titles != nil {
+			shaped[i]["titles"] = append([]data.MovieTitle{}, titles[movie.ID]...)
+		}
+		if releases != nil {
+			shaped[i]["releases"] = append([]data.MovieRelease{}, releases[movie.ID]...)
+		}
+	}
+
+	return shaped, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/http"
+)
+
+// "PUT /v1/users/me/following/:id" - trenutni korisnik počinje da prati korisnika sa datim ID-em
+func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Follows.Follow(app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrSelfFollow):
+			v := validator.New()
+			v.AddError("id", "you cannot follow yourself")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user successfully followed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "DELETE /v1/users/me/following/:id"
+func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Follows.Unfollow(app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user successfully unfollowed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "GET /v1/users/me/following" i "GET /v1/users/me/followers" - praćeni korisnici i pratioci trenutnog korisnika
+func (app *application) listFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.models.Follows.GetFollowing)
+}
+
+func (app *application) listFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.models.Follows.GetFollowers)
+}
+
+func (app *application) listFollows(w http.ResponseWriter, r *http.Request, get func(int64, data.Filters) ([]*data.FollowedUser, data.Metadata, error)) {
+	filters, ok := app.readFeedFilters(w, r)
+	if !ok {
+		return
+	}
+
+	users, metadata, err := get(app.contextGetUser(r).ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"users": users, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "GET /v1/users/me/feed" - aktivnosti korisnika koje trenutni korisnik prati (ocjene, komentari), od najnovije
+func (app *application) showFeedHandler(w http.ResponseWriter, r *http.Request) {
+	filters, ok := app.readFeedFilters(w, r)
+	if !ok {
+		return
+	}
+
+	activities, metadata, err := app.models.Activities.GetFeed(app.contextGetUser(r).ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"activities": activities, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// paginacija za liste koje se uvijek sortiraju od najnovijeg zapisa
+// ukoliko parametri nisu ispravni, odgovor je već poslat i vraća se "false"
+func (app *application) readFeedFilters(w http.ResponseWriter, r *http.Request) (data.Filters, bool) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "page_size", 20, v),
+		Sort:         "-created_at",
+		SortSafeList: []string{"-created_at"},
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return filters, false
+	}
+
+	return filters, true
+}
@@ -7,10 +7,12 @@ import (
 	"github.com/julienschmidt/httprouter"
 	validator "greenlight.lazarmrkic.com/internal"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func (app *application) readIDParam(r *http.Request) (int64, error) {
@@ -153,6 +155,22 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 	return nil
 }
 
+// vraća "media type" iz "Content-Type" header-a, bez dodatnih parametara (recimo "charset")
+// ukoliko header nije poslat, podrazumijeva se "application/json", a ukoliko ne može da se pročita, vraća se prazan "string"
+func (app *application) requestMediaType(r *http.Request) string {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return "application/json"
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	return mediaType
+}
+
 // metoda koja vraća "string" vrijednost iz "query string"-a ili vraća "default" vrijednost
 // "qs" predstavlja "query string"
 func (app *application) readString(qs url.Values, key string, defaultValue string) string {
@@ -194,11 +212,82 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+// provjera da li neki od "ETag"-ova iz "If-Match" / "If-None-Match" header-a odgovara zadatom "ETag"-u
+// header može da sadrži listu vrijednosti odvojenih zarezom ili "*" (odgovara bilo kojoj verziji resursa)
+// "If-None-Match" koristi "weak" poređenje (ignoriše se "W/" prefiks), dok "If-Match" zahtijeva "strong" poređenje
+func (app *application) etagMatches(values []string, etag string, weak bool) bool {
+	for _, value := range values {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+
+			if candidate == "*" {
+				return true
+			}
+
+			if weak {
+				if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+					return true
+				}
+				continue
+			}
+
+			if !strings.HasPrefix(candidate, "W/") && candidate == etag {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// vraća "true" ukoliko je klijent poslao "If-Match" header, a nijedna njegova vrijednost ne odgovara trenutnom "ETag"-u
+// ukoliko header nije poslat, nema uslova koji bi mogao da "padne"
+// lokalizovane varijante ("7-1a2b3c4d") opisuju istu verziju zapisa, pa se prije poređenja svode na "movieETag()" oblik
+func (app *application) ifMatchFailed(r *http.Request, etag string) bool {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		return false
+	}
+
+	var candidates []string
+	for _, value := range values {
+		for _, candidate := range strings.Split(value, ",") {
+			candidates = append(candidates, unlocalizedMovieETag(strings.TrimSpace(candidate)))
+		}
+	}
+
+	return !app.etagMatches(candidates, etag, false)
+}
+
+// ova metoda učitava vremensku vrijednost iz "query string"-a
+// prihvata se RFC 3339 format ("2023-10-01T15:04:05Z") ili samo datum ("2023-10-01", podrazumijeva se ponoć po UTC-u)
+func (app *application) readTime(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t
+		}
+	}
+
+	v.AddError(key, "must be a valid RFC 3339 timestamp or date (YYYY-MM-DD)")
+	return defaultValue
+}
+
 // ova funkcija služi za "panic recovery"
 // ona koristi "recover()" da uhvati svaki "panic" i da izvrši logovanje "error" poruke umjesto direktnog gašenja aplikacije
 func (app *application) background(fn func()) {
+	// "WaitGroup" brojač se povećava prije pokretanja, kako bi "serve()" sačekao da se posao završi prilikom gašenja
+	app.wg.Add(1)
+
 	// pokretanje "goroutine" u pozadini:
 	go func() {
+		defer app.wg.Done()
+
 		// "recover" proces za svaki "panic"
 		defer func() {
 			if err := recover(); err != nil {
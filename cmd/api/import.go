@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maksimalna veličina fajla za uvoz (32MB)
+	importMaxBytes = 32 << 20
+	// broj redova koji se ubacuju unutar jedne transakcije (u "best_effort" režimu)
+	importBatchSize = 500
+	// najveći broj redova u "atomic" režimu - čitav fajl se drži u memoriji i čuva unutar jedne transakcije
+	importAtomicMaxRows = 5000
+)
+
+// izvještaj za jedan red iz fajla
+// "status" može da bude "created", "failed" ili "skipped" (red je validan, ali "atomic" uvoz nije izvršen)
+type importRow struct {
+	Row    int               `json:"row"`
+	Status string            `json:"status"`
+	ID     int64             `json:"id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type importSummary struct {
+	Mode    string `json:"mode"`
+	Total   int    `json:"total"`
+	Created int    `json:"created"`
+	Failed  int    `json:"failed"`
+}
+
+// zajednički interfejs za čitanje filmova iz CSV i NDJSON fajlova
+// "Read()" vraća naredni film i greške vezane za taj red (npr. "year" nije broj)
+// greška (treća povratna vrijednost) znači da fajl ne može dalje da se čita - "io.EOF" označava kraj fajla
+type movieReader interface {
+	Read() (*data.Movie, map[string]string, error)
+}
+
+// "POST /v1/movies/import" - uvoz većeg broja filmova jednim zahtjevom
+// prihvata "text/csv" i "application/x-ndjson" formate, a svaki red se validira preko "ValidateMovie()"
+//
+// "mode" parametar određuje ponašanje u slučaju grešaka:
+// "best_effort" (default) - čuvaju se svi validni redovi, a nevalidni se samo prijavljuju
+// "atomic" - ukoliko bilo koji red nije validan, nijedan film se ne čuva (najviše "importAtomicMaxRows" redova, u suprotnom "413 Request Entity Too Large")
+//
+// ukoliko upis nekog "batch"-a ne uspije u "best_effort" režimu, ranije sačuvani "batch"-evi ostaju u bazi
+// uvoz se tada prekida, a klijent dobija "500" odgovor sa izvještajem do tog trenutka (redovi iz neuspjelog "batch"-a su označeni kao "failed")
+func (app *application) importMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	mode := app.readString(r.URL.Query(), "mode", "best_effort")
+	v.Check(validator.PermittedValue(mode, "best_effort", "atomic"), "mode", "must be either best_effort or atomic")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// uvoz većeg fajla traje duže od "ReadTimeout"/"WriteTimeout" vrijednosti servera
+	// zbog toga se rokovi produžavaju samo za ovaj zahtjev
+	rc := http.NewResponseController(w)
+	err := rc.SetReadDeadline(time.Now().Add(5 * time.Minute))
+	if err == nil {
+		err = rc.SetWriteDeadline(time.Now().Add(5 * time.Minute))
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, importMaxBytes)
+
+	var reader movieReader
+
+	switch app.requestMediaType(r) {
+	case "text/csv":
+		reader, err = newCSVMovieReader(body)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	case "application/x-ndjson", "application/jsonl":
+		reader = newNDJSONMovieReader(body)
+	default:
+		app.unsupportedMediaTypeResponse(w, r)
+		return
+	}
+
+	// žanrovi se učitavaju jednom za čitav uvoz
+	taxonomy, err := app.genreTaxonomy()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	atomic := mode == "atomic"
+	summary := importSummary{Mode: mode}
+	rows := []importRow{}
+
+	// "batch" sadrži validne filmove koji čekaju na upis, a "pending" indekse njihovih redova unutar izvještaja
+	var batch []*data.Movie
+	var pending []int
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		rowErrors, err := app.models.Movies.InsertBatch(batch, atomic)
+		if err != nil {
+			// transakcija je poništena, pa nijedan film iz ovog "batch"-a nije sačuvan
+			for _, i := range pending {
+				rows[i].Status = "failed"
+				rows[i].Errors = map[string]string{"row": "the batch containing this row could not be saved"}
+				summary.Failed++
+			}
+
+			batch, pending = nil, nil
+			return err
+		}
+
+		app.wakeOutbox()
+
+		// u "atomic" režimu, greška u bilo kom redu poništava čitavu transakciju
+		saved := true
+		for _, err := range rowErrors {
+			if err != nil && atomic {
+				saved = false
+			}
+		}
+
+		for i, movie := range batch {
+			row := &rows[pending[i]]
+
+			switch {
+			case errors.Is(rowErrors[i], data.ErrDuplicateExternalKey):
+				row.Status = "failed"
+				row.Errors = map[string]string{"external_key": "a movie with this external key already exists"}
+				summary.Failed++
+			case rowErrors[i] != nil:
+				app.logError(r, rowErrors[i])
+				row.Status = "failed"
+				row.Errors = map[string]string{"row": "the movie could not be saved"}
+				summary.Failed++
+			case saved:
+				row.Status = "created"
+				row.ID = movie.ID
+				summary.Created++
+			default:
+				row.Status = "skipped"
+			}
+		}
+
+		batch, pending = nil, nil
+		return nil
+	}
+
+	// greška prilikom upisa u "best_effort" režimu prekida uvoz
+	var interrupted error
+
+	for {
+		movie, rowErrors, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				err = fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+			}
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		summary.Total++
+		row := importRow{Row: summary.Total}
+
+		if atomic && summary.Total > importAtomicMaxRows {
+			app.importTooLargeResponse(w, r, importAtomicMaxRows)
+			return
+		}
+
+		rv := validator.New()
+		for key, message := range rowErrors {
+			rv.AddError(key, message)
+		}
+
+		if data.ValidateMovie(rv, movie, taxonomy); !rv.Valid() {
+			row.Status = "failed"
+			row.Errors = rv.Errors
+			summary.Failed++
+			rows = append(rows, row)
+			continue
+		}
+
+		rows = append(rows, row)
+		batch = append(batch, movie)
+		pending = append(pending, len(rows)-1)
+
+		// u "atomic" režimu svi filmovi se čuvaju unutar jedne transakcije, pa se "batch" ne prazni prije kraja fajla
+		if !atomic && len(batch) >= importBatchSize {
+			interrupted = flush()
+			if interrupted != nil {
+				break
+			}
+		}
+	}
+
+	// u "atomic" režimu se ništa ne čuva ukoliko neki red nije prošao validaciju
+	if interrupted == nil && (!atomic || summary.Failed == 0) {
+		err = flush()
+		if err != nil {
+			// u "atomic" režimu nijedan film nije sačuvan, pa izvještaj nije potreban
+			if atomic {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			interrupted = err
+		}
+	}
+
+	// validni redovi koji su ostali u "batch"-u nisu sačuvani:
+	for _, i := range pending {
+		rows[i].Status = "skipped"
+	}
+
+	status := http.StatusOK
+	env := envelope{"import": summary, "rows": rows}
+
+	switch {
+	case interrupted != nil:
+		app.logError(r, interrupted)
+		status = http.StatusInternalServerError
+		env["error"] = "the import was interrupted by a server error, only the rows reported as created were saved"
+	case atomic && summary.Failed > 0:
+		status = http.StatusUnprocessableEntity
+		env["error"] = "no movies were imported because some rows are invalid"
+	}
+
+	err = app.writeJSON(w, status, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// CSV fajl mora da sadrži "header" red sa nazivima kolona
+// obavezne kolone su "title", "year", "runtime" i "genres", a "external_key" je opciona
+// "id" i "version" kolone (iz "GET /v1/movies/export?format=csv") su dozvoljene, ali se zanemaruju
+// žanrovi se unutar jedne ćelije odvajaju preko "|" karaktera (recimo "drama|romance")
+// "runtime" može da bude broj minuta ("102") ili u istom formatu kao u JSON-u ("102 mins")
+type csvMovieReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVMovieReader(body io.Reader) (*csvMovieReader, error) {
+	reader := csv.NewReader(body)
+	// broj kolona u svakom redu se provjerava ručno, kako bi se greška prijavila samo za taj red
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must not be empty")
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if !validator.PermittedValue(name, "id", "title", "year", "runtime", "genres", "external_key", "version") {
+			return nil, fmt.Errorf("csv header contains unknown column %q", name)
+		}
+		// u suprotnom bi vrijednost jedne od kolona bila tiho zanemarena u svakom redu
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("csv header contains duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range []string{"title", "year", "runtime", "genres"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv header must contain the %q column", name)
+		}
+	}
+
+	return &csvMovieReader{reader: reader, columns: columns}, nil
+}
+
+func (c *csvMovieReader) Read() (*data.Movie, map[string]string, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	movie := &data.Movie{}
+	rowErrors := make(map[string]string)
+
+	if len(record) != len(c.columns) {
+		rowErrors["row"] = fmt.Sprintf("must contain %d fields", len(c.columns))
+		return movie, rowErrors, nil
+	}
+
+	movie.Title = record[c.columns["title"]]
+
+	if s := strings.TrimSpace(record[c.columns["year"]]); s != "" {
+		year, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			rowErrors["year"] = "must be an integer value"
+		}
+		movie.Year = int32(year)
+	}
+
+	if s := strings.TrimSpace(record[c.columns["runtime"]]); s != "" {
+		runtime, err := strconv.ParseInt(strings.TrimSuffix(s, " mins"), 10, 32)
+		if err != nil {
+			rowErrors["runtime"] = "must be an integer number of minutes"
+		}
+		movie.Runtime = data.Runtime(runtime)
+	}
+
+	if s := strings.TrimSpace(record[c.columns["genres"]]); s != "" {
+		for _, genre := range strings.Split(s, "|") {
+			movie.Genres = append(movie.Genres, strings.TrimSpace(genre))
+		}
+	}
+
+	if i, ok := c.columns["external_key"]; ok {
+		movie.ExternalKey = strings.TrimSpace(record[i])
+	}
+
+	return movie, rowErrors, nil
+}
+
+// NDJSON ("newline-delimited JSON") - svaki red je zaseban JSON objekat u istom formatu kao za "POST /v1/movies"
+// prazni redovi se preskaču
+type ndjsonMovieReader struct {
+	scanner *bufio.Scanner
+}
+
+func newNDJSONMovieReader(body io.Reader) *ndjsonMovieReader {
+	scanner := bufio.NewScanner(body)
+	// jedan red može da ima najviše 1MB, isto kao i tijelo zahtjeva za "POST /v1/movies"
+	scanner.Buffer(make([]byte, 64*1024), 1_048_576)
+
+	return &ndjsonMovieReader{scanner: scanner}
+}
+
+func (n *ndjsonMovieReader) Read() (*data.Movie, map[string]string, error) {
+	var line []byte
+
+	for len(line) == 0 {
+		if !n.scanner.Scan() {
+			if err := n.scanner.Err(); err != nil {
+				return nil, nil, err
+			}
+			return nil, nil, io.EOF
+		}
+		line = bytes.TrimSpace(n.scanner.Bytes())
+	}
+
+	var input struct {
+		Title       string       `json:"title"`
+		Year        int32        `json:"year"`
+		Runtime     data.Runtime `json:"runtime"`
+		Genres      []string     `json:"genres"`
+		ExternalKey string       `json:"external_key"`
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(&input)
+	if err != nil {
+		return &data.Movie{}, map[string]string{"row": ndjsonRowError(err)}, nil
+	}
+
+	movie := &data.Movie{
+		Title:       input.Title,
+		Year:        input.Year,
+		Runtime:     input.Runtime,
+		Genres:      input.Genres,
+		ExternalKey: input.ExternalKey,
+	}
+
+	return movie, nil, nil
+}
+
+// "ndjsonRowError()" pretvara grešku dekodiranja u poruku za klijenta, po uzoru na "readJSON()"
+// poruke "json" paketa sadrže nazive Go tipova i polja, pa se ne vraćaju direktno
+func ndjsonRowError(err error) string {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		return fmt.Sprintf("contains badly-formed JSON (at character %d)", syntaxError.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "contains badly-formed JSON"
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field != "" {
+			return fmt.Sprintf("contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+		}
+		return fmt.Sprintf("contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Sprintf("contains unknown key %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	case errors.Is(err, data.ErrInvalidRuntimeFormat):
+		return err.Error()
+	default:
+		return "contains invalid JSON"
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// "startJobs()" pokreće sve pozadinske poslove aplikacije
+// poslovi rade dok se "ctx" ne otkaže, a "serve()" nakon gašenja servera čeka da se završe (preko "app.wg")
+func (app *application) startJobs(ctx context.Context) {
+	app.runJob(ctx, "scheduler", app.runScheduler)
+	app.runJob(ctx, "recommendations", app.runRecommendations)
+	app.runJob(ctx, "view-writer", app.runViewWriter)
+	app.runJob(ctx, "views", app.runViewAggregation)
+	app.runJob(ctx, "outbox", app.runOutboxDispatcher)
+	app.runJob(ctx, "webhooks", app.runWebhookDeliveries)
+	app.runJob(ctx, "movie-events", app.runMovieEventListener)
+}
+
+// "runJob()" pokreće posao u zasebnoj "goroutine"-i
+// kao i kod "background()" helper-a, "panic" se loguje umjesto da ugasi aplikaciju
+func (app *application) runJob(ctx context.Context, name string, job func(ctx context.Context)) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error(fmt.Sprintf("%v", err), "job", name)
+			}
+		}()
+
+		app.logger.Info("starting background job", "job", name)
+		job(ctx)
+		app.logger.Info("stopped background job", "job", name)
+	}()
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// jezici koje klijent preferira, sortirani po prioritetu
+// "locale" parametar ima prednost nad "Accept-Language" header-om (recimo "Accept-Language: sr-RS, sr;q=0.9, en;q=0.5")
+func (app *application) readLocales(r *http.Request, v *validator.Validator) []string {
+	if locale := app.readString(r.URL.Query(), "locale", ""); locale != "" {
+		locale = strings.ToLower(locale)
+		v.Check(data.LocaleRX.MatchString(locale), "locale", "must be a valid locale (for example \"sr\" or \"en-US\")")
+		return []string{locale}
+	}
+
+	type preference struct {
+		locale  string
+		quality float64
+	}
+
+	var preferences []preference
+
+	for _, value := range r.Header.Values("Accept-Language") {
+		for _, part := range strings.Split(value, ",") {
+			locale, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			locale = strings.ToLower(strings.TrimSpace(locale))
+
+			quality := 1.0
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				parsed, err := strconv.ParseFloat(q, 64)
+				if err != nil {
+					continue
+				}
+				quality = parsed
+			}
+
+			// neispravne vrijednosti se ignorišu, jer "header" šalje pretraživač, a ne korisnik
+			if quality <= 0 || !data.LocaleRX.MatchString(locale) {
+				continue
+			}
+
+			preferences = append(preferences, preference{locale: locale, quality: quality})
+		}
+	}
+
+	// "stable" sortiranje čuva redoslijed iz "header"-a za jezike sa istim prioritetom
+	slices.SortStableFunc(preferences, func(a, b preference) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	locales := make([]string, len(preferences))
+	for i, p := range preferences {
+		locales[i] = p.locale
+	}
+
+	return locales
+}
+
+// "localizeMovies()" popunjava "LocalizedTitle" polje naslovom koji najbolje odgovara preferiranim jezicima
+// ukoliko klijent nije naveo jezik, baza se ne poziva
+func (app *application) localizeMovies(movies []*data.Movie, locales []string) error {
+	if len(locales) == 0 || len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	titles, err := app.models.Localizations.GetTitles(ids)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		if title, ok := data.MatchLocale(locales, titles[movie.ID]); ok {
+			movie.LocalizedTitle = title.Title
+		}
+	}
+
+	return nil
+}
+
+func (app *application) showMovieLocalizationsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.movieVisible(r, movie) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	titles, err := app.models.Localizations.GetTitles([]int64{id})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	releases, err := app.models.Localizations.GetReleases([]int64{id})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"titles":   append([]data.MovieTitle{}, titles[id]...),
+		"releases": append([]data.MovieRelease{}, releases[id]...),
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "PUT /v1/movies/:id/localizations" zamjenjuje sve alternativne naslove i datume izlaska filma
+// oba polja su obavezna - prazna lista briše postojeće vrijednosti
+func (app *application) updateMovieLocalizationsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if app.ifMatchFailed(r, movieETag(movie)) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Titles   []data.MovieTitle   `json:"titles"`
+		Releases []data.MovieRelease `json:"releases"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Titles != nil, "titles", "must be provided")
+	v.Check(input.Releases != nil, "releases", "must be provided")
+
+	if data.ValidateLocalizations(v, input.Titles, input.Releases); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Localizations.Replace(movie, app.contextGetUser(r).ID, input.Titles, input.Releases)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.wakeOutbox()
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	// lokalizacije objavljenog filma čekaju odobrenje (pogledati "data.MovieEdit")
+	status := http.StatusOK
+	env := envelope{"titles": input.Titles, "releases": input.Releases}
+	if movie.PendingEdit != nil {
+		status = http.StatusAccepted
+		env["pending_edit"] = movie.PendingEdit
+	}
+
+	err = app.writeJSON(w, status, env, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
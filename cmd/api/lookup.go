@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/http"
+	"strings"
+)
+
+// "GET /v1/movies/lookup?source=imdb&id=tt0111161" - pronalaženje filma preko identifikatora iz spoljnog kataloga
+func (app *application) lookupMovieHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	source := strings.ToLower(app.readString(qs, "source", ""))
+	id := strings.TrimSpace(app.readString(qs, "id", ""))
+
+	v.Check(source != "", "source", "must be provided")
+	v.Check(id != "", "id", "must be provided")
+
+	if source != "" {
+		_, ok := data.ExternalIDSources[source]
+		v.Check(ok, "source", "must be either imdb or tmdb")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.GetByExternalID(source, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.movieVisible(r, movie) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"flag"
+	"greenlight.lazarmrkic.com/internal/cache"
 	"greenlight.lazarmrkic.com/internal/data"
 	"greenlight.lazarmrkic.com/internal/mailer"
 	"log/slog"
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -42,6 +45,9 @@ type config struct {
 		burst int
 		// "limiter" po potrebi palimo ili gasimo
 		enabled bool
+		// "autocomplete" se poziva na svaki pritisak tastera, pa ima zaseban "budget"
+		autocompleteRps   float64
+		autocompleteBurst int
 	}
 
 	smtp struct {
@@ -51,6 +57,14 @@ type config struct {
 		password string
 		sender   string
 	}
+
+	// podešavanja pozadinskih poslova
+	jobs struct {
+		// koliko često se ponovo računa sličnost filmova za preporuke
+		recommendationsInterval time.Duration
+		// koliko često se pojedinačni pregledi filmova sabiraju u "movie_view_stats"
+		viewsInterval time.Duration
+	}
 }
 
 type application struct {
@@ -58,6 +72,26 @@ type application struct {
 	logger *slog.Logger
 	models data.Models
 	mailer mailer.Mailer
+	// keš za "autocomplete" prijedloge (ključ je kombinacija "limit" vrijednosti i teksta pretrage)
+	autocompleteCache *cache.Cache[string, []data.MovieSuggestion]
+	// keš za referentnu tabelu žanrova (sadrži samo jedan unos)
+	genreCache *cache.Cache[string, data.GenreTaxonomy]
+	// keš za slične filmove (ključ sadrži ID i verziju filma, pogledati "listSimilarMoviesHandler")
+	similarCache *cache.Cache[string, similarMoviesPage]
+	// signal za "scheduler" da je raspored izmijenjen
+	schedulerWake chan struct{}
+	// HTTP klijent za isporuke pretplaćenim spoljnim sistemima i signal za "deliverer" da u redu postoje nove isporuke
+	webhookClient *http.Client
+	webhooksWake  chan struct{}
+	// signal za "outbox dispatcher" da su upisane nove poruke
+	outboxWake chan struct{}
+	// otvorene "GET /v1/events/movies" konekcije
+	movieStream *movieStream
+	// pregledi filmova koji još nisu upisani u "movie_views" i signal za "writer" da je grupa popunjena
+	views     *viewBuffer
+	viewsWake chan struct{}
+	// prati pozadinske "goroutine"-e, kako bi se sačekale prilikom gašenja servera
+	wg sync.WaitGroup
 }
 
 func main() {
@@ -78,6 +112,8 @@ func main() {
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	// "rate limiting" će po default-u biti uključen
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+	flag.Float64Var(&cfg.limiter.autocompleteRps, "limiter-autocomplete-rps", 10, "Rate limiter maximum requests per second for autocomplete")
+	flag.IntVar(&cfg.limiter.autocompleteBurst, "limiter-autocomplete-burst", 20, "Rate limiter maximum burst for autocomplete")
 
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "sandbox.smtp.mailtrap.io", "SMTP host")
 	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
@@ -85,6 +121,9 @@ func main() {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "bf736dfe60444b", "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@greenlight.lazarmrkic.com>", "SMTP sender")
 
+	flag.DurationVar(&cfg.jobs.viewsInterval, "views-interval", time.Minute, "Interval between aggregating movie views into hourly statistics")
+	flag.DurationVar(&cfg.jobs.recommendationsInterval, "recommendations-interval", time.Hour, "Interval between recomputing movie similarities for recommendations")
+
 	flag.Parse()
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
@@ -104,6 +143,23 @@ func main() {
 		logger: logger,
 		models: data.NewModels(db),
 		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		// prijedlozi se čuvaju 30 sekundi, pa novi filmovi postaju vidljivi brzo i bez eksplicitnog brisanja keša
+		autocompleteCache: cache.New[string, []data.MovieSuggestion](30*time.Second, 10_000),
+		genreCache:        cache.New[string, data.GenreTaxonomy](time.Minute, 1),
+		similarCache:      cache.New[string, similarMoviesPage](5*time.Minute, 10_000),
+		schedulerWake:     make(chan struct{}, 1),
+		// preusmjerenja se ne prate, a primalac mora da odgovori u roku od 10 sekundi
+		webhookClient: &http.Client{
+			Timeout: 10 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		webhooksWake: make(chan struct{}, 1),
+		outboxWake:   make(chan struct{}, 1),
+		movieStream:  newMovieStream(),
+		views:        &viewBuffer{},
+		viewsWake:    make(chan struct{}, 1),
 	}
 
 	// pokretanje servera:
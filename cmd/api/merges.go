@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/http"
+	"strconv"
+)
+
+// "GET /v1/movies/duplicates" - izvještaj o filmovima koji su vjerovatno duplikati
+// parametri: "threshold" (sličnost naslova, podrazumijevano 0.6), "year_tolerance" (0), "runtime_tolerance" (10) i paginacija
+func (app *application) listDuplicateMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	criteria := data.DuplicateCriteria{
+		Threshold:        0.6,
+		YearTolerance:    app.readInt(qs, "year_tolerance", 0, v),
+		RuntimeTolerance: app.readInt(qs, "runtime_tolerance", 10, v),
+	}
+
+	if s := qs.Get("threshold"); s != "" {
+		threshold, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			v.AddError("threshold", "must be a decimal number")
+		}
+		criteria.Threshold = threshold
+	}
+
+	filters := data.Filters{
+		Page:     app.readInt(qs, "page", 1, v),
+		PageSize: app.readInt(qs, "page_size", 20, v),
+		// parovi se uvijek sortiraju po sličnosti
+		Sort:         "similarity",
+		SortSafeList: []string{"similarity"},
+	}
+
+	data.ValidateDuplicateCriteria(v, criteria)
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	candidates, metadata, err := app.models.Movies.GetDuplicateCandidates(criteria, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"duplicates": candidates, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "POST /v1/movies/:id/merge" - spajanje duplikata ("duplicate_id") u kanonski film (":id")
+// duplikat se briše, a zahtjevi za njegov ID se nakon toga preusmjeravaju ("301 Moved Permanently") na kanonski film
+// "If-Match" header se odnosi na kanonski film
+func (app *application) mergeMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		DuplicateID int64 `json:"duplicate_id"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.DuplicateID > 0, "duplicate_id", "must be provided")
+	v.Check(input.DuplicateID != id, "duplicate_id", "must not be the same as the canonical movie")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if app.ifMatchFailed(r, movieETag(movie)) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	// verzija se provjerava samo uz "If-Match", inače spajanje ne zavisi od izmjena kanonskog filma
+	var version int32
+	if r.Header.Get("If-Match") != "" {
+		version = movie.Version
+	}
+
+	user := app.contextGetUser(r)
+
+	merge, err := app.models.Merges.Merge(input.DuplicateID, id, version, user.ID)
+	if err != nil {
+		switch {
+		// kanonski film je provjeren iznad, pa ovo znači da duplikat ne postoji (ili je obrisan u međuvremenu)
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("duplicate_id", "movie not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movie, err = app.models.Movies.Get(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.wakeOutbox()
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie, "merge": merge}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ukoliko je traženi film spojen u neki drugi, šalje se "301 Moved Permanently" sa adresom kanonskog filma
+// ukoliko preusmjerenje ne postoji, ili korisnik ne vidi kanonski film (pa ne smije da sazna ni njegov ID), šalje se "404 Not Found"
+func (app *application) redirectMergedMovie(w http.ResponseWriter, r *http.Request, id int64) {
+	targetID, err := app.models.Merges.GetRedirect(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	target, err := app.models.Movies.Get(targetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.movieVisible(r, target) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	location := fmt.Sprintf("/v1/movies/%d", targetID)
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", location)
+
+	err = app.writeJSON(w, http.StatusMovedPermanently, envelope{"message": "the movie has been merged into another movie", "location": location}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
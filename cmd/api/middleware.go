@@ -94,23 +94,32 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 				return
 			}
 
+			// "autocomplete" zahtjevi se broje odvojeno od ostalih i imaju veći "budget"
+			// ključ u mapi je zbog toga kombinacija IP adrese i naziva "budget"-a
+			key := ip
+			rps, burst := app.config.limiter.rps, app.config.limiter.burst
+			if r.URL.Path == "/v1/movies/autocomplete" {
+				key = ip + "|autocomplete"
+				rps, burst = app.config.limiter.autocompleteRps, app.config.limiter.autocompleteBurst
+			}
+
 			//zaključavanje "mutex"-a, kako se ovaj kod ne bi izvšavao konkurentno
 			mu.Lock()
 
 			// provjera da li IP adresa postoji unutar mape
 			// ukoliko ne, inicijalizuje se novi klijent (unutar kog su "limiter" i "last seen") i dodaje se u mapu skupa sa povezanom IP adresom
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{
-					limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
+			if _, found := clients[key]; !found {
+				clients[key] = &client{
+					limiter: rate.NewLimiter(rate.Limit(rps), burst),
 				}
 			}
 
 			// ažuriranje "last seen" vremena
-			clients[ip].lastSeen = time.Now()
+			clients[key].lastSeen = time.Now()
 
 			// za trenutnu IP adresu se poziva "Allow()" metoda
 			// ukoliko "request" nije dozvoljen, "mutex" se otključava i na kraju se šalje "429 Too Many Requests" odgovor
-			if !clients[ip].limiter.Allow() {
+			if !clients[key].limiter.Allow() {
 				mu.Unlock()
 				app.rateLimitExceededResponse(w, r)
 				return
@@ -235,6 +244,9 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 			return
 		}
 
+		// "handler" može dodatno da prilagodi odgovor na osnovu ostalih "permission"-a (recimo, vidljivost neobjavljenih filmova)
+		r = app.contextSetPermissions(r, permissions)
+
 		next.ServeHTTP(w, r)
 	}
 
@@ -5,7 +5,13 @@ import (
 	"fmt"
 	validator "greenlight.lazarmrkic.com/internal"
 	"greenlight.lazarmrkic.com/internal/data"
+	"greenlight.lazarmrkic.com/internal/jsonpatch"
+	"hash/fnv"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
@@ -16,6 +22,10 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		Year    int32        `json:"year"`
 		Runtime data.Runtime `json:"runtime"`
 		Genres  []string     `json:"genres"`
+		// opcioni identifikator koji dodjeljuje klijent:
+		ExternalKey string `json:"external_key"`
+		// opcioni identifikatori iz spoljnih kataloga (recimo {"imdb": "tt0111161"}):
+		ExternalIDs map[string]string `json:"external_ids"`
 	}
 
 	// stari pristup za dekodiranje:
@@ -33,10 +43,20 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		Year:    input.Year,
 		Runtime: input.Runtime,
 		Genres:  input.Genres,
+
+		ExternalKey: input.ExternalKey,
+		ExternalIDs: input.ExternalIDs,
 	}
 
 	v := validator.New()
-	if data.ValidateMovie(v, movie); !v.Valid() {
+
+	taxonomy, err := app.genreTaxonomy()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if data.ValidateMovie(v, movie, taxonomy); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
@@ -45,15 +65,28 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	// ovo će kreirati novi upis u bazu, a biće odrađeno i AŽURIRANJE tri polja unutar struct-a sa generisanim informacijama
 	err = app.models.Movies.Insert(movie)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateExternalKey):
+			v.AddError("external_key", "a movie with this external key already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			v.AddError("external_ids", "contains an id that is already assigned to another movie")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
+	// "movie.created" poruka je upisana u "outbox" skupa sa filmom
+	app.wakeOutbox()
+
 	// kada šaljemo HTTP response, onda unutar njega šaljemo i "Location" header
 	// unutar njega će biti URL na kom mogu da nađu resurs koji je upravo kreiran
 	// prvo se pravi prazna HTTP "header" mapa, a nakon toga dodajemo novi "Location" header
 	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/v1/movies/%id", movie.ID))
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	headers.Set("ETag", movieETag(movie))
 
 	// sada se upisuju vrijednosti u JSON odgovor sa HTTP 201 kodom, skupa sa "Location" header-om:
 	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
@@ -70,20 +103,91 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	v := validator.New()
+
+	// "fields" ograničava ključeve u odgovoru, a "include" ugrađuje povezane resurse
+	fields, includes := app.readMovieFieldsets(r.URL.Query(), v)
+	locales := app.readLocales(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	movie, err := app.models.Movies.Get(id)
 	if err != nil {
 		switch {
-		// ukoliko se desi "data.ErrRecordNotFound", onda šaljemo "404 Not Found" ka klijentu
+		// film koji je spojen u drugi film se preusmjerava, a u suprotnom šaljemo "404 Not Found" ka klijentu
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.redirectMergedMovie(w, r, id)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
+	// neobjavljeni filmovi ne postoje za korisnike koji samo čitaju katalog
+	if !app.movieVisible(r, movie) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	app.recordMovieView(movie.ID)
+
+	err = app.localizeMovies([]*data.Movie{movie}, locales)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// lokalizovani naslov zavisi od "Accept-Language" header-a, pa keš mora da ga uzme u obzir
+	// header se postavlja direktno na odgovor, kako bi bio poslat i uz "304 Not Modified"
+	w.Header().Set("Vary", "Accept-Language")
+
+	headers := make(http.Header)
+
+	// "ETag" je vezan za verziju zapisa
+	// ukoliko klijent već ima trenutnu verziju filma (šalje je preko "If-None-Match" header-a), vraća se "304 Not Modified" bez tijela odgovora
+	//
+	// ugrađeni resursi se mijenjaju nezavisno od verzije filma, pa se u tom slučaju "ETag" ne šalje
+	if len(includes) == 0 {
+		etag := localizedMovieETag(movie)
+		if app.etagMatches(r.Header.Values("If-None-Match"), etag, true) {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		headers.Set("ETag", etag)
+	}
+
+	shaped, err := app.shapeMovies([]*data.Movie{movie}, fields, includes)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// "shapeMovies()" vraća isti tip koji je primila ("[]*data.Movie") ili "[]map[string]any"
+	var output any = movie
+	if shapedMovies, ok := shaped.([]map[string]any); ok {
+		output = shapedMovies[0]
+	}
+
+	env := envelope{"movie": output}
+
+	// urednici vide i izmjenu koja čeka odobrenje, dok korisnici koji samo čitaju katalog vide samo objavljenu verziju
+	if app.canEditMovies(r) {
+		edit, err := app.models.Reviews.GetPendingEdit(movie.ID)
+		switch {
+		case err == nil:
+			env["pending_edit"] = edit
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
 	// ubacivanje "envelope{"movie": movie}" instance:
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeJSON(w, http.StatusOK, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -102,6 +206,9 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	movie, err := app.models.Movies.Get(id)
 	if err != nil {
 		switch {
+		// uslov iz "If-Match" header-a (pa i "*") ne može biti ispunjen ukoliko zapis ne postoji (RFC 9110)
+		case errors.Is(err, data.ErrRecordNotFound) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
 		default:
@@ -110,6 +217,119 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// ukoliko klijent pošalje "If-Match" header, izmjena se odobrava samo ako se "ETag" poklapa sa trenutnom verzijom zapisa
+	// u suprotnom, vraća se "412 Precondition Failed"
+	if app.ifMatchFailed(r, movieETag(movie)) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	// izmjena objavljenog filma nastavlja od izmjene koja već čeka odobrenje
+	err = app.applyPendingEdit(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// pored "bespoke" formata sa "pointer" poljima ("application/json"), podržani su i standardni formati za djelimične izmjene:
+	// "application/merge-patch+json" (RFC 7396) - može da "obriše" polje preko "null" vrijednosti
+	// "application/json-patch+json" (RFC 6902) - niz operacija, uključujući i rad sa elementima niza (recimo "/genres/-")
+	switch app.requestMediaType(r) {
+	case mergePatchMediaType:
+		var patch any
+		err = app.readJSON(w, r, &patch)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		err = patchMovie(movie, func(doc any) (any, error) {
+			return jsonpatch.MergePatch(doc, patch), nil
+		})
+	case jsonPatchMediaType:
+		var patch jsonpatch.Patch
+		err = app.readJSON(w, r, &patch)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		err = patchMovie(movie, patch.Apply)
+	// "Content-Type" koji ne može da se pročita se odbija, kako se ne bi pogrešno protumačio kao "bespoke" format
+	case "":
+		app.unsupportedMediaTypeResponse(w, r)
+		return
+	// svi ostali tipovi ("application/json", ali i "text/plain" ili "application/x-www-form-urlencoded" koje šalje "curl -d")
+	// se, kao i prije uvođenja standardnih formata, čitaju kao "bespoke" JSON
+	default:
+		err = app.readMovieInput(w, r, movie)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	// greške mogu da nastanu samo prilikom primjene "patch" dokumenta
+	// neuspješna "test" operacija znači da se stanje zapisa razlikuje od onoga što klijent očekuje
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			app.patchConflictResponse(w, r, err)
+		default:
+			app.unprocessablePatchResponse(w, r, err)
+		}
+		return
+	}
+
+	// validacija ažuriranog zapisa iz baze
+	// ukoliko ona ne prođe - biće poslat "422 Unprocessable Entity" odgovor
+	v := validator.New()
+
+	taxonomy, err := app.genreTaxonomy()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if data.ValidateMovie(v, movie, taxonomy); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// prosljeđivanje ažuriranog zapisa u "update()" metodu, sada on treba nanovo da se sačuva u bazu:
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		// ukoliko je zapis izmijenjen nakon "If-Match" provjere, uslov klijenta više ne važi:
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			v.AddError("external_ids", "contains an id that is already assigned to another movie")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+
+		}
+		return
+	}
+
+	app.wakeOutbox()
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	// vraćanje ažuriranog zapisa u vidu JSON odgovora:
+	status, env := movieWriteResponse(movie, http.StatusOK)
+	err = app.writeJSON(w, status, env, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "readMovieInput()" učitava "bespoke" format za djelimične izmjene i upisuje poslata polja u "movie" zapis
+func (app *application) readMovieInput(w http.ResponseWriter, r *http.Request, movie *data.Movie) error {
 	// How do we tell the difference between:
 	// A client providing a key/value pair which has a zero-value value — like {"title": ""} — in which case we want to return a validation error.
 	// A client not providing a key/value pair in their JSON at all — in which case we want to ‘skip’ updating the field but not send a validation error.
@@ -124,13 +344,14 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		Year    *int32        `json:"year"`
 		Runtime *data.Runtime `json:"runtime"`
 		Genres  []string      `json:"genres"` // nizove nema potrebe da modifikujemo, oni su referentni tip
+		// poslata mapa u potpunosti zamjenjuje postojeće identifikatore
+		ExternalIDs map[string]string `json:"external_ids"`
 	}
 
 	// upisivanje vrijednosti iz klijentskog JSON-a u "input" struct:
-	err = app.readJSON(w, r, &input)
+	err := app.readJSON(w, r, &input)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
-		return
+		return err
 	}
 
 	// kopiranje vrijednosti iz "request body"-ja u odgovarajuća polja "movie" zapisa iz baze
@@ -149,35 +370,194 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	if input.Genres != nil {
 		movie.Genres = input.Genres // nema potrebe da derefenciramo "slice"
 	}
+	if input.ExternalIDs != nil {
+		movie.ExternalIDs = input.ExternalIDs
+	}
+
+	return nil
+}
+
+// "PUT" zahtjev vrši potpunu zamjenu filma ("complete replacement")
+// za razliku od "PATCH"-a, klijent mora da pošalje SVA polja - polje koje nedostaje se prijavljuje kao greška pri validaciji
+func (app *application) replaceMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		// uslov iz "If-Match" header-a (pa i "*") ne može biti ispunjen ukoliko zapis ne postoji (RFC 9110)
+		case errors.Is(err, data.ErrRecordNotFound) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if app.ifMatchFailed(r, movieETag(movie)) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	// "external_key" i spoljni identifikatori (ukoliko nisu poslati) se preuzimaju iz izmjene koja već čeka odobrenje
+	err = app.applyPendingEdit(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
-	// validacija ažuriranog zapisa iz baze
-	// ukoliko ona ne prođe - biće poslat "422 Unprocessable Entity" odgovor
 	v := validator.New()
-	if data.ValidateMovie(v, movie); !v.Valid() {
+
+	err = app.readMovieReplacement(w, r, movie, v)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	taxonomy, err := app.genreTaxonomy()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if data.ValidateMovie(v, movie, taxonomy); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	// prosljeđivanje ažuriranog zapisa u "update()" metodu, sada on treba nanovo da se sačuva u bazu:
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
 	if err != nil {
 		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			v.AddError("external_ids", "contains an id that is already assigned to another movie")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
-
 		}
 		return
 	}
 
-	// vraćanje ažuriranog zapisa u vidu JSON odgovora:
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	app.wakeOutbox()
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	status, env := movieWriteResponse(movie, http.StatusOK)
+	err = app.writeJSON(w, status, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// "upsert" na osnovu identifikatora koji bira klijent ("PUT /v1/movies?external_key=...")
+// ukoliko film sa datim ključem ne postoji, on se kreira ("201 Created"), a u suprotnom se u potpunosti zamjenjuje ("200 OK")
+// ponovljeni zahtjev sa istim podacima nema dodatnih efekata, pa "ingestion pipeline" može bezbjedno da ga ponavlja
+func (app *application) upsertMovieHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	movie := &data.Movie{
+		ExternalKey: app.readString(r.URL.Query(), "external_key", ""),
+	}
+
+	v.Check(movie.ExternalKey != "", "external_key", "must be provided")
+
+	err := app.readMovieReplacement(w, r, movie, v)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// "upsert" mijenja samo kolone "movies" tabele, pa se identifikatori postavljaju preko "PATCH /v1/movies/:id"
+	v.Check(movie.ExternalIDs == nil, "external_ids", "cannot be set through upsert")
+
+	taxonomy, err := app.genreTaxonomy()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if data.ValidateMovie(v, movie, taxonomy); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	result, err := app.models.Movies.Upsert(movie, app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	status := http.StatusOK
+	if result == data.UpsertCreated {
+		status = http.StatusCreated
+		headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	}
+
+	// ponovljeni "upsert" sa istim podacima nije izmjena, a izmjena koja čeka odobrenje nije javna - u oba slučaja nema poruke u "outbox"-u
+	if result == data.UpsertCreated || result == data.UpsertUpdated {
+		app.wakeOutbox()
+	}
+
+	// ponovljeni "upsert" izmjene koja čeka odobrenje takođe vraća "202 Accepted", uz izmjenu
+	status, env := movieWriteResponse(movie, status)
+	err = app.writeJSON(w, status, env, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "readMovieReplacement()" učitava kompletnu reprezentaciju filma i upisuje je u "movie"
+// "pointer" polja koristimo kako bismo razlikovali polje koje nije poslato od polja sa "zero" vrijednošću
+// svako polje koje nedostaje se dodaje u "Validator" instancu kao greška
+func (app *application) readMovieReplacement(w http.ResponseWriter, r *http.Request, movie *data.Movie, v *validator.Validator) error {
+	var input struct {
+		Title   *string       `json:"title"`
+		Year    *int32        `json:"year"`
+		Runtime *data.Runtime `json:"runtime"`
+		Genres  []string      `json:"genres"`
+		// opciono polje - ukoliko nije poslato, postojeći identifikatori se ne mijenjaju
+		ExternalIDs map[string]string `json:"external_ids"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		return err
+	}
+
+	v.Check(input.Title != nil, "title", "must be provided")
+	v.Check(input.Year != nil, "year", "must be provided")
+	v.Check(input.Runtime != nil, "runtime", "must be provided")
+	v.Check(input.Genres != nil, "genres", "must be provided")
+
+	movie.Title, movie.Year, movie.Runtime, movie.Genres = "", 0, 0, input.Genres
+	movie.ExternalIDs = input.ExternalIDs
+
+	if input.Title != nil {
+		movie.Title = *input.Title
+	}
+	if input.Year != nil {
+		movie.Year = *input.Year
+	}
+	if input.Runtime != nil {
+		movie.Runtime = *input.Runtime
+	}
+
+	return nil
+}
+
 func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -185,17 +565,38 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	// uslovno brisanje - zapis se briše samo ukoliko "If-Match" odgovara trenutnoj verziji
+	// verzija se dodatno provjerava i unutar samog "DELETE" upita, za slučaj da se zapis izmijeni u međuvremenu
+	if r.Header.Get("If-Match") != "" {
+		var movie *data.Movie
+		movie, err = app.models.Movies.Get(id)
+		if err == nil {
+			if app.ifMatchFailed(r, movieETag(movie)) {
+				app.preconditionFailedResponse(w, r)
+				return
+			}
+			err = app.models.Movies.DeleteVersion(id, movie.Version)
+		}
+	} else {
+		err = app.models.Movies.Delete(id)
+	}
+
 	if err != nil {
 		switch {
+		case errors.Is(err, data.ErrRecordNotFound) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailedResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
+	app.wakeOutbox()
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -205,18 +606,31 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	// unutar ovog "struct"-a će se čuvati očekivane vrijednosti iz "request query string"-a:
 	var input struct {
-		Title  string
-		Genres []string
+		// kriterijumi za filtriranje ("title", "genres", "year_min",...)
+		data.MovieCriteria
 		// ubacivanje "Filters" struct-a ("page" / "page_size" i "sort")
 		data.Filters
+		// opcioni "facet"-i - broj rezultata po žanru, deceniji i dužini trajanja
+		Facets []string
 	}
 
 	v := validator.New()
 	// "url.Values" mapa, koja sadrži "query string" podatke
 	qs := r.URL.Query()
 
-	input.Title = app.readString(qs, "title", "")
-	input.Genres = app.readCSV(qs, "genres", []string{})
+	taxonomy, err := app.genreTaxonomy()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	input.MovieCriteria = app.readMovieCriteria(qs, v, taxonomy)
+	if !app.canEditMovies(r) {
+		input.MovieCriteria.PublishedOnly = true
+	}
+	input.Facets = app.readCSV(qs, "facets", []string{})
+	fields, includes := app.readMovieFieldsets(qs, v)
+	locales := app.readLocales(r, v)
 	// podrazumijevana vrijednost za "page_value" je 1, a za "page_size" je 20
 	// treći argument koji prosljeđujemo je instanca "validator"-a
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
@@ -224,7 +638,18 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 	// podrazumijevana vrijednost za sortiranje je "id" (ascending sortiranje preko "movie ID"-a)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
 	// dodavanje podržanih "sort" vrijednosti za ovaj "endpoint"
-	input.Filters.SortSafeList = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	// "relevance" sortira rezultate po tome koliko se naslov poklapa sa "title" parametrom, a "popularity" po broju pregleda u poslednjih 7 dana
+	input.Filters.SortSafeList = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime", "relevance", "popularity"}
+	// "cursor" parametar uključuje "keyset" paginaciju (prazna vrijednost označava prvu stranicu)
+	// bez njega se i dalje koristi paginacija preko "page" broja
+	input.Filters.UseCursor = qs.Has("cursor")
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+
+	// vrijednosti "relevance" i "popularity" ocjena ne mogu da se sačuvaju u kursoru
+	v.Check(!(input.Filters.UseCursor && input.Filters.Sort == "relevance"), "sort", "relevance sort is not supported with cursor pagination")
+	v.Check(!(input.Filters.UseCursor && input.Filters.Sort == "popularity"), "sort", "popularity sort is not supported with cursor pagination")
+
+	data.ValidateFacets(v, input.Facets)
 
 	// validacija nad "Filters" struct-om i provjera da li ima grešaka u "Validator" instanci
 	// ukoliko se pronađu greške, biće poslat odgovor sa njihovim sadržajem
@@ -233,15 +658,119 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieCriteria, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.localizeMovies(movies, locales)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	shaped, err := app.shapeMovies(movies, fields, includes)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	env := envelope{"movies": shaped, "metadata": metadata}
+
+	// "facet"-i se računaju samo ukoliko ih je klijent tražio:
+	if len(input.Facets) > 0 {
+		facets, err := app.models.Movies.GetFacets(input.MovieCriteria, input.Facets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		env["facets"] = facets
+	}
+
+	headers := make(http.Header)
+	headers.Set("Vary", "Accept-Language")
+
 	// "JSON response" koji sadrži podatke o filmovima:
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	err = app.writeJSON(w, http.StatusOK, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// učitavanje kriterijuma za filtriranje filmova iz "query string"-a (koriste ih "GET /v1/movies" i "GET /v1/movies/export")
+// greške pri parsiranju i validaciji se upisuju u "Validator" instancu
+// žanrovi se prevode u "slug"-ove preko "genres" taksonomije (pogledati "data.ValidateMovieCriteria()")
+func (app *application) readMovieCriteria(qs url.Values, v *validator.Validator, genres data.GenreTaxonomy) data.MovieCriteria {
+	var criteria data.MovieCriteria
+
+	criteria.Title = app.readString(qs, "title", "")
+	// način pretrage po naslovu - "fulltext" (default) ili "fuzzy" (tolerantna na greške u kucanju)
+	search := app.readString(qs, "search", "fulltext")
+	v.Check(validator.PermittedValue(search, "fulltext", "fuzzy"), "search", "must be either fulltext or fuzzy")
+	criteria.Fuzzy = search == "fuzzy"
+
+	criteria.Genres = app.readCSV(qs, "genres", []string{})
+	criteria.GenresAny = app.readCSV(qs, "genres_any", []string{})
+	criteria.GenresExclude = app.readCSV(qs, "genres_exclude", []string{})
+
+	criteria.YearMin = app.readInt(qs, "year_min", 0, v)
+	criteria.YearMax = app.readInt(qs, "year_max", 0, v)
+	criteria.RuntimeMin = app.readInt(qs, "runtime_min", 0, v)
+	criteria.RuntimeMax = app.readInt(qs, "runtime_max", 0, v)
+
+	criteria.CreatedAfter = app.readTime(qs, "created_after", time.Time{}, v)
+	criteria.CreatedBefore = app.readTime(qs, "created_before", time.Time{}, v)
+
+	// filtriranje po fazi uređivačkog procesa (korisnici bez "movies:write" permission-a uvijek vide samo trenutno objavljene filmove)
+	criteria.Statuses = app.readCSV(qs, "status", []string{})
+
+	data.ValidateMovieCriteria(v, &criteria, genres)
+
+	return criteria
+}
+
+// "ETag" za film se izvodi iz "Version" polja
+// svaka izmjena zapisa uvećava verziju, pa se samim tim mijenja i "ETag"
+// u pitanju je "strong" validator, jer ista verzija uvijek znači i identičan sadržaj zapisa
+func movieETag(movie *data.Movie) string {
+	return fmt.Sprintf(`"%d"`, movie.Version)
+}
+
+// "localizedMovieETag()" razlikuje lokalizovane varijante iste verzije filma
+// "sr" i "en" odgovor imaju različito tijelo, pa ne smiju da dijele "strong" "ETag" (keš bi inače mogao da vrati pogrešnu varijantu)
+// varijanta bez lokalizovanog naslova zadržava "ETag" iz "movieETag()"
+func localizedMovieETag(movie *data.Movie) string {
+	if movie.LocalizedTitle == "" {
+		return movieETag(movie)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(movie.LocalizedTitle))
+
+	return fmt.Sprintf(`"%d-%08x"`, movie.Version, h.Sum32())
+}
+
+// "unlocalizedMovieETag()" uklanja sufiks koji dodaje "localizedMovieETag()" ("7-1a2b3c4d" -> "7")
+// sve ostale vrijednosti ("W/" validatori, "*", nepoznati formati) se vraćaju nepromijenjene
+func unlocalizedMovieETag(etag string) string {
+	if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) || len(etag) < 2 {
+		return etag
+	}
+
+	version, suffix, found := strings.Cut(etag[1:len(etag)-1], "-")
+	if !found || len(suffix) != 8 {
+		return etag
+	}
+
+	if _, err := strconv.ParseInt(version, 10, 64); err != nil {
+		return etag
+	}
+
+	if _, err := strconv.ParseUint(suffix, 16, 32); err != nil {
+		return etag
+	}
+
+	return `"` + version + `"`
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"greenlight.lazarmrkic.com/internal/data"
+	"greenlight.lazarmrkic.com/internal/events"
+	"time"
+)
+
+const (
+	// broj poruka koje se preuzimaju u jednom prolazu
+	outboxBatchSize = 20
+	// najduži razmak između dvije provjere "outbox"-a (nove poruke bude "dispatcher" preko "wakeOutbox()")
+	outboxPollInterval = 5 * time.Second
+	// koliko često se brišu poruke starije od "data.OutboxRetention"
+	outboxCleanupInterval = time.Hour
+)
+
+// "handler" jedne teme iz "outbox"-a
+// poruka se isporučuje "najmanje jednom" - ukoliko se proces ugasi prije bilježenja rezultata, poruka se obrađuje ponovo
+type outboxHandler func(message *data.OutboxMessage) error
+
+// "outboxHandlers()" vraća "handler" za svaku temu koju upisuju modeli (pogledati "data.insertOutbox()")
+func (app *application) outboxHandlers() map[string]outboxHandler {
+	handlers := map[string]outboxHandler{
+		data.OutboxUserRegistered: app.sendWelcomeEmail,
+		data.OutboxUserActivated:  app.forwardUserEvent,
+		data.OutboxMovieCreated:   app.forwardMovieEvent,
+		data.OutboxMovieUpdated:   app.forwardMovieEvent,
+		data.OutboxMovieDeleted:   app.forwardMovieEvent,
+	}
+
+	// promjene statusa filma ("movie.published", "movie.archived",...)
+	for _, status := range data.MovieStatuses {
+		handlers[data.OutboxMovieStatus(status)] = app.forwardMovieEvent
+	}
+
+	return handlers
+}
+
+// "wakeOutbox()" obavještava "dispatcher" da su u "outbox" upisane nove poruke
+// poziva se nakon potvrđene transakcije - čak i kada se ne pozove, poruka se obrađuje nakon "outboxPollInterval"
+func (app *application) wakeOutbox() {
+	select {
+	case app.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// "runOutboxDispatcher()" prosljeđuje poruke iz "outbox"-a registrovanim "handler"-ima (pogledati "OutboxModel.ClaimDue()" i "RecordAttempt()")
+// dok u "outbox"-u ima poruka koje čekaju, preuzimaju se bez pauze, a u suprotnom se čeka najduže "outboxPollInterval"
+// posao takođe briše obrađene poruke starije od "data.OutboxRetention"
+func (app *application) runOutboxDispatcher(ctx context.Context) {
+	handlers := app.outboxHandlers()
+
+	cleanup := time.NewTicker(outboxCleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		// brisanje se provjerava na početku svakog prolaza, kako se ne bi odlagalo dok u "outbox"-u stalno ima poruka
+		select {
+		case <-cleanup.C:
+			deleted, err := app.models.Outbox.DeleteExpired()
+			if err != nil {
+				app.logger.Error(err.Error(), "job", "outbox")
+			} else if deleted > 0 {
+				app.logger.Info("deleted expired outbox messages", "deleted", deleted)
+			}
+		default:
+		}
+
+		messages, err := app.models.Outbox.ClaimDue(outboxBatchSize)
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "outbox")
+		}
+
+		for _, message := range messages {
+			// aplikacija se gasi - preostale poruke ostaju rezervisane i obrađuju se nakon isteka rezervacije
+			if ctx.Err() != nil {
+				return
+			}
+
+			app.dispatchOutboxMessage(handlers, message)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if len(messages) == outboxBatchSize {
+			continue
+		}
+
+		timer := time.NewTimer(outboxPollInterval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-app.outboxWake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func (app *application) dispatchOutboxMessage(handlers map[string]outboxHandler, message *data.OutboxMessage) {
+	handler, ok := handlers[message.Topic]
+
+	var err error
+	if ok {
+		err = handler(message)
+	} else {
+		err = fmt.Errorf("no handler registered for topic %q", message.Topic)
+	}
+
+	recordErr := app.models.Outbox.RecordAttempt(message, err)
+	if recordErr != nil {
+		app.logger.Error(recordErr.Error(), "job", "outbox", "message_id", message.ID)
+		return
+	}
+
+	switch message.Status {
+	case data.OutboxPending:
+		app.logger.Warn("outbox message will be retried", "message_id", message.ID, "topic", message.Topic, "error", message.LastError)
+	case data.OutboxFailed:
+		app.logger.Error("outbox message failed", "message_id", message.ID, "topic", message.Topic, "error", message.LastError)
+	}
+}
+
+// "sendWelcomeEmail()" obrađuje "user.registered" poruku: kreira "activation token" i šalje "welcome" email
+// svaki pokušaj kreira novi token - token iz neuspješnog pokušaja nikada nije poslat, a ističe sam
+func (app *application) sendWelcomeEmail(message *data.OutboxMessage) error {
+	var user struct {
+		ID    int64  `json:"id"`
+		Email string `json:"email"`
+	}
+
+	err := json.Unmarshal(message.Payload, &user)
+	if err != nil {
+		return err
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+	if err != nil {
+		return err
+	}
+
+	templateData := map[string]any{
+		"activationToken": token.Plaintext,
+		"userID":          user.ID,
+	}
+
+	return app.mailer.Send(user.Email, "user_welcome.tmpl", templateData)
+}
+
+// "forwardMovieEvent()" obrađuje poruke o filmovima ("movie.created", "movie.updated", "movie.deleted" i promjene statusa):
+// loguje događaj i upisuje isporuke za pretplate
+// "Data" događaja je film u trenutku izmjene (isti kao u odgovoru na zahtjev), a za obrisan film samo njegov ID
+func (app *application) forwardMovieEvent(message *data.OutboxMessage) error {
+	var movie struct {
+		ID int64 `json:"id"`
+	}
+
+	err := json.Unmarshal(message.Payload, &movie)
+	if err != nil {
+		return err
+	}
+
+	event := events.Event{Type: message.Topic, MovieID: movie.ID, OccurredAt: message.CreatedAt, Data: message.Payload}
+
+	app.logger.Info("event", "type", event.Type, "movie_id", event.MovieID)
+
+	return app.enqueueWebhookEvent(event)
+}
+
+// "forwardUserEvent()" obrađuje "user.activated" poruku: loguje događaj i upisuje isporuke za pretplate
+// "Data" događaja je sadržaj poruke ({"id": 1, "name": "..."})
+func (app *application) forwardUserEvent(message *data.OutboxMessage) error {
+	event := events.Event{Type: message.Topic, OccurredAt: message.CreatedAt, Data: message.Payload}
+
+	app.logger.Info("event", "type", event.Type)
+
+	return app.enqueueWebhookEvent(event)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"greenlight.lazarmrkic.com/internal/data"
+	"greenlight.lazarmrkic.com/internal/jsonpatch"
+	"maps"
+	"strings"
+)
+
+const (
+	mergePatchMediaType = "application/merge-patch+json"
+	jsonPatchMediaType  = "application/json-patch+json"
+)
+
+// JSON reprezentacija filma nad kojom se primjenjuju "patch" dokumenti
+// sadrži samo polja koja klijent smije da mijenja ("id" i "version" nisu dio dokumenta)
+// za razliku od "Movie" struct-a, ovdje nema "omitempty" tagova - svako polje mora da postoji kako bi "replace" i "test" operacije radile
+type movieDocument struct {
+	Title   string       `json:"title"`
+	Year    int32        `json:"year"`
+	Runtime data.Runtime `json:"runtime"`
+	Genres  []string     `json:"genres"`
+	// "remove" / "null" nad čitavim poljem briše sve spoljne identifikatore
+	ExternalIDs map[string]string `json:"external_ids"`
+}
+
+// "patchMovie()" pretvara film u JSON dokument, primjenjuje "apply" funkciju nad njim i upisuje rezultat nazad u "movie"
+// rezultat se dekodira u prazan "movieDocument", pa polja koja je "patch" uklonio ostaju sa "zero" vrijednošću
+// (nakon toga će ih "ValidateMovie()" prijaviti kao obavezna)
+func patchMovie(movie *data.Movie, apply func(doc any) (any, error)) error {
+	js, err := json.Marshal(movieDocument{
+		Title:   movie.Title,
+		Year:    movie.Year,
+		Runtime: movie.Runtime,
+		Genres:  movie.Genres,
+
+		ExternalIDs: movie.ExternalIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	doc, err := jsonpatch.Decode(js)
+	if err != nil {
+		return err
+	}
+
+	doc, err = apply(doc)
+	if err != nil {
+		return err
+	}
+
+	js, err = json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	// "patch" ne smije da doda polja koja ne postoje u "Movie" zapisu:
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+
+	var result movieDocument
+	err = dec.Decode(&result)
+	if err != nil {
+		return patchResultError(err)
+	}
+
+	movie.Title = result.Title
+	movie.Year = result.Year
+	movie.Runtime = result.Runtime
+	movie.Genres = result.Genres
+
+	// spoljni identifikatori se mijenjaju samo ukoliko ih je "patch" dotakao
+	// ("remove" / "null" nad čitavim poljem daje praznu mapu, pa "Update()" briše sve identifikatore)
+	if !maps.Equal(result.ExternalIDs, movie.ExternalIDs) {
+		movie.ExternalIDs = result.ExternalIDs
+		if movie.ExternalIDs == nil {
+			movie.ExternalIDs = map[string]string{}
+		}
+	}
+
+	return nil
+}
+
+// "patchResultError()" pretvara grešku dekodiranja "patch"-ovanog dokumenta u poruku za klijenta, po uzoru na "readJSON()"
+// dokument je nastao iz "json.Marshal()", pa sintaksne greške nisu moguće - ostaju pogrešan tip, nepoznat ključ i neispravan "runtime"
+func patchResultError(err error) error {
+	var unmarshalTypeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field != "" {
+			return fmt.Errorf("patched document contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+		}
+		return errors.New("patched document must be a JSON object")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("patched document contains unknown key %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	case errors.Is(err, data.ErrInvalidRuntimeFormat):
+		return fmt.Errorf("patched document contains %w for field \"runtime\"", err)
+	default:
+		return errors.New("patched document is not a valid movie")
+	}
+}
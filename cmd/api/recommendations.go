@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/http"
+	"time"
+)
+
+// "runRecommendations()" periodično ponovo računa sličnost filmova na osnovu ocjena (pogledati "RefreshSimilarities()")
+// prvo računanje se izvršava odmah nakon pokretanja aplikacije
+func (app *application) runRecommendations(ctx context.Context) {
+	ticker := time.NewTicker(app.config.jobs.recommendationsInterval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+
+		count, err := app.models.Recommendations.RefreshSimilarities(ctx)
+		if err != nil && ctx.Err() == nil {
+			app.logger.Error(err.Error(), "job", "recommendations")
+		} else if err == nil {
+			app.logger.Info("movie similarities refreshed", "pairs", count, "duration", time.Since(start).String())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// "GET /v1/users/me/recommendations" - preporučeni filmovi za trenutnog korisnika, uz paginaciju
+// preporuke se računaju iz unaprijed izračunatih sličnosti, pa nove ocjene utiču na njih odmah,
+// a nove sličnosti tek nakon narednog osvježavanja
+func (app *application) listRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters := data.Filters{
+		Page:     app.readInt(qs, "page", 1, v),
+		PageSize: app.readInt(qs, "page_size", 20, v),
+		// rezultati se uvijek sortiraju po ocjeni preporuke
+		Sort:         "score",
+		SortSafeList: []string{"score"},
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	movies, metadata, err := app.models.Recommendations.GetForUser(user.ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "PUT /v1/movies/:id/rating" - ocjena filma od strane trenutnog korisnika: {"rating": 8}
+// ponovno slanje mijenja postojeću ocjenu
+func (app *application) rateMovieHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.readVisibleMovie(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Rating *int `json:"rating"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Rating != nil, "rating", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	rating := &data.Rating{
+		UserID:  app.contextGetUser(r).ID,
+		MovieID: movie.ID,
+		Rating:  *input.Rating,
+	}
+
+	if data.ValidateRating(v, rating); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Ratings.Upsert(rating)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"rating": rating}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "DELETE /v1/movies/:id/rating" - uklanjanje ocjene trenutnog korisnika
+func (app *application) deleteMovieRatingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Ratings.Delete(app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "rating successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "PUT /v1/movies/:id/watched" - film se označava kao odgledan i više se ne preporučuje korisniku
+func (app *application) markMovieWatchedHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.readVisibleMovie(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Ratings.MarkWatched(app.contextGetUser(r).ID, movie.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie marked as watched"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "DELETE /v1/movies/:id/watched"
+func (app *application) unmarkMovieWatchedHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Ratings.UnmarkWatched(app.contextGetUser(r).ID, id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie unmarked as watched"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// učitavanje filma iz ":id" parametra, koji mora da bude vidljiv trenutnom korisniku
+// ukoliko film nije pronađen, odgovor je već poslat i vraća se "false"
+func (app *application) readVisibleMovie(w http.ResponseWriter, r *http.Request) (*data.Movie, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !app.movieVisible(r, movie) {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return movie, true
+}
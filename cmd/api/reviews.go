@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/http"
+	"time"
+)
+
+// korisnici sa "movies:write" permission-om vide filmove u svim fazama,
+// a ostali samo objavljene filmove unutar njihovog "publish_at"/"unpublish_at" okvira
+func (app *application) canEditMovies(r *http.Request) bool {
+	return app.contextGetPermissions(r).Include("movies:write")
+}
+
+func (app *application) movieVisible(r *http.Request, movie *data.Movie) bool {
+	return movie.Published(time.Now()) || app.canEditMovies(r)
+}
+
+// "applyPendingEdit()" upisuje u "movie" izmjenu koja čeka odobrenje (ukoliko postoji),
+// kako bi nova izmjena objavljenog filma nastavila od nje, umjesto od objavljene verzije
+func (app *application) applyPendingEdit(movie *data.Movie) error {
+	edit, err := app.models.Reviews.GetPendingEdit(movie.ID)
+	switch {
+	case err == nil:
+		edit.ApplyTo(movie)
+	case !errors.Is(err, data.ErrRecordNotFound):
+		return err
+	}
+
+	return nil
+}
+
+// "movieWriteResponse()" vraća status i tijelo odgovora nakon izmjene filma
+// izmjena objavljenog filma čeka odobrenje, pa se vraća "202 Accepted" sa objavljenom verzijom i izmjenom ("pending_edit")
+func movieWriteResponse(movie *data.Movie, status int) (int, envelope) {
+	if movie.PendingEdit == nil {
+		return status, envelope{"movie": movie}
+	}
+
+	return http.StatusAccepted, envelope{"movie": movie, "pending_edit": movie.PendingEdit}
+}
+
+// "transitionMovieHandler()" vraća "handler" za jednu akciju iz "data.MovieTransitions" mape
+// ("POST /v1/movies/:id/submit", ".../approve", ".../reject" i ".../archive")
+// "approve" i "reject" nad objavljenim filmom se odnose na izmjenu koja čeka odobrenje
+// tijelo zahtjeva je opciono i sadrži komentar recenzenta: {"comment": "..."}
+func (app *application) transitionMovieHandler(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readIDParam(r)
+		if err != nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		var input struct {
+			Comment string `json:"comment"`
+		}
+
+		if r.ContentLength != 0 {
+			err = app.readJSON(w, r, &input)
+			if err != nil {
+				app.badRequestResponse(w, r, err)
+				return
+			}
+		}
+
+		review := &data.MovieReview{
+			UserID:  app.contextGetUser(r).ID,
+			Action:  action,
+			Comment: input.Comment,
+		}
+
+		v := validator.New()
+		if data.ValidateMovieReview(v, review); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		movie, err := app.models.Movies.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if app.ifMatchFailed(r, movieETag(movie)) {
+			app.preconditionFailedResponse(w, r)
+			return
+		}
+
+		err = app.models.Reviews.Transition(movie, review)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrInvalidTransition):
+				app.invalidTransitionResponse(w, r, action, movie.Status)
+			case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+				app.preconditionFailedResponse(w, r)
+			case errors.Is(err, data.ErrEditConflict):
+				app.editConflictResponse(w, r)
+			// odobrena izmjena može da dodijeli ključ ili identifikator koji je u međuvremenu dobio drugi film
+			case errors.Is(err, data.ErrDuplicateExternalKey):
+				v.AddError("external_key", "is already assigned to another movie")
+				app.failedValidationResponse(w, r, v.Errors)
+			case errors.Is(err, data.ErrDuplicateExternalID):
+				v.AddError("external_ids", "contains an id that is already assigned to another movie")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		app.wakeOutbox()
+
+		// odobren film može da čeka objavu u "scheduled" statusu
+		if review.ToStatus == data.MovieStatusScheduled {
+			app.wakeScheduler()
+		}
+
+		headers := make(http.Header)
+		headers.Set("ETag", movieETag(movie))
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie, "review": review}, headers)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	}
+}
+
+// "GET /v1/movies/:id/reviews" - istorija prelaza i komentara recenzenata
+func (app *application) listMovieReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	reviews, err := app.models.Reviews.GetAllForMovie(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reviews": reviews}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -19,15 +19,94 @@ func (app *application) routes() http.Handler {
 
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requirePermission("movies:read", app.listMoviesHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requirePermission("movies:read", app.showMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id", app.subroutes(app.methodNotAllowedResponse, map[string]http.HandlerFunc{
+		"import": app.requirePermission("movies:write", app.importMoviesHandler),
+	}))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/merge", app.requirePermission("movies:write", app.mergeMovieHandler))
+	// uređivački proces: autori šalju film na recenziju, a korisnici sa "movies:publish" permission-om ga objavljuju ili vraćaju
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/submit", app.requirePermission("movies:write", app.transitionMovieHandler("submit")))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/approve", app.requirePermission("movies:publish", app.transitionMovieHandler("approve")))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/reject", app.requirePermission("movies:publish", app.transitionMovieHandler("reject")))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/archive", app.requirePermission("movies:publish", app.transitionMovieHandler("archive")))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/reviews", app.requirePermission("movies:write", app.listMovieReviewsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/schedule", app.requirePermission("movies:publish", app.updateMovieScheduleHandler))
+	// statičke rute ispod "/v1/movies/" se razrješavaju preko "subroutes()" (pogledati komentar ispod)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.subroutes(app.requirePermission("movies:read", app.showMovieHandler), map[string]http.HandlerFunc{
+		"export":       app.requirePermission("movies:read", app.exportMoviesHandler),
+		"autocomplete": app.requirePermission("movies:read", app.autocompleteMoviesHandler),
+		"lookup":       app.requirePermission("movies:read", app.lookupMovieHandler),
+		"duplicates":   app.requirePermission("movies:write", app.listDuplicateMoviesHandler),
+		"trending":     app.requirePermission("movies:read", app.listTrendingMoviesHandler),
+	}))
 	// ukoliko radimo "partial update", onda trebamo da koristimo "PATCH":
 	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
+	// "PUT" vrši potpunu zamjenu, a nad kolekcijom služi za "upsert" preko "external_key" parametra:
+	router.HandlerFunc(http.MethodPut, "/v1/movies", app.requirePermission("movies:write", app.upsertMovieHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id", app.requirePermission("movies:write", app.replaceMovieHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/similar", app.requirePermission("movies:read", app.listSimilarMoviesHandler))
+	// ocjene i odgledani filmovi trenutnog korisnika (koriste se za preporuke):
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/rating", app.requirePermission("movies:read", app.rateMovieHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/rating", app.requirePermission("movies:read", app.deleteMovieRatingHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/watched", app.requirePermission("movies:read", app.markMovieWatchedHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/watched", app.requirePermission("movies:read", app.unmarkMovieWatchedHandler))
+	// komentari (niti sa odgovorima) i moderacija prijavljenih komentara:
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/comments", app.requirePermission("movies:read", app.listMovieCommentsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/comments", app.requirePermission("movies:read", app.createCommentHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/comments/:id", app.requirePermission("movies:read", app.updateCommentHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/comments/:id", app.requirePermission("movies:read", app.deleteCommentHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/comments/:id/revisions", app.requirePermission("movies:read", app.listCommentRevisionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/comments/:id/reports", app.requirePermission("movies:read", app.reportCommentHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/moderation/comments", app.requirePermission("comments:moderate", app.listReportedCommentsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/moderation/comments/:id/resolve", app.requirePermission("comments:moderate", app.resolveCommentReportsHandler))
+	// alternativni naslovi i datumi izlaska po zemljama:
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/localizations", app.requirePermission("movies:read", app.showMovieLocalizationsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/localizations", app.requirePermission("movies:write", app.updateMovieLocalizationsHandler))
+
+	// "Server-Sent Events" stream izmjena filmova:
+	router.HandlerFunc(http.MethodGet, "/v1/events/movies", app.requirePermission("movies:read", app.streamMovieEventsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.requirePermission("movies:read", app.listGenresHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres", app.requirePermission("genres:write", app.createGenreHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/genres/:id", app.requirePermission("movies:read", app.showGenreHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/genres/:id", app.requirePermission("genres:write", app.updateGenreHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/genres/:id", app.requirePermission("genres:write", app.deleteGenreHandler))
+
+	// pretplate spoljnih sistema na događaje i log isporuka:
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks", app.requirePermission("webhooks:write", app.listWebhooksHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/webhooks", app.requirePermission("webhooks:write", app.createWebhookHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks/:id", app.requirePermission("webhooks:write", app.showWebhookHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/webhooks/:id", app.requirePermission("webhooks:write", app.updateWebhookHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/webhooks/:id", app.requirePermission("webhooks:write", app.deleteWebhookHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/webhooks/:id/ping", app.requirePermission("webhooks:write", app.pingWebhookHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks/:id/deliveries", app.requirePermission("webhooks:write", app.listWebhookDeliveriesHandler))
 
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/recommendations", app.requirePermission("movies:read", app.listRecommendationsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/feed", app.requirePermission("movies:read", app.showFeedHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/following", app.requirePermission("movies:read", app.listFollowingHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/followers", app.requirePermission("movies:read", app.listFollowersHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/me/following/:id", app.requirePermission("movies:read", app.followUserHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/following/:id", app.requirePermission("movies:read", app.unfollowUserHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/activation", app.createActivationTokenHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
 
 	return app.recoverPanic(app.rateLimit(app.authenticate(router)))
 }
+
+// "httprouter" ne dozvoljava da statička ruta (recimo "/v1/movies/export") dijeli segment sa ":id" parametrom
+// zbog toga se takve rute registruju preko "subroutes" mape:
+// ukoliko vrijednost ":id" segmenta odgovara nekom ključu iz mape, poziva se taj "handler", a u suprotnom "byID" handler
+func (app *application) subroutes(byID http.HandlerFunc, subroutes map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+
+		if next, ok := subroutes[params.ByName("id")]; ok {
+			next(w, r)
+			return
+		}
+
+		byID(w, r)
+	}
+}
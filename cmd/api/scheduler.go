@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/http"
+	"time"
+)
+
+// najduži razmak između dvije provjere rasporeda
+// raspored može da izmijeni i druga instanca aplikacije, pa se ne oslanjamo isključivo na "wakeScheduler()"
+const schedulerMaxWait = time.Minute
+
+// "runScheduler()" objavljuje zakazane filmove i arhivira filmove kojima je istekao "unpublish_at"
+// raspored se čuva u Postgres-u, pa se nakon restarta odmah izvršavaju svi prelazi koji su propušteni
+// nakon svake provjere, "scheduler" čeka do sledećeg zakazanog trenutka (najduže "schedulerMaxWait")
+func (app *application) runScheduler(ctx context.Context) {
+	for {
+		reviews, err := app.models.Reviews.ApplySchedules()
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "scheduler")
+		}
+
+		for _, review := range reviews {
+			app.logger.Info("scheduled transition", "movie_id", review.MovieID, "action", review.Action, "status", review.ToStatus)
+		}
+
+		if len(reviews) > 0 {
+			app.wakeOutbox()
+		}
+
+		wait := schedulerMaxWait
+
+		next, err := app.models.Movies.NextScheduledAt()
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "scheduler")
+		} else if next != nil && time.Until(*next) < wait {
+			// sat baze i sat aplikacije ne moraju da se poklapaju, pa se provjera ponavlja najčešće jednom u sekundi
+			wait = max(time.Until(*next), time.Second)
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-app.schedulerWake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// "wakeScheduler()" obavještava "scheduler" da se raspored promijenio (recimo, novi "publish_at" za nekoliko sekundi)
+// kanal ima "buffer" od jednog elementa, pa višestruki pozivi između dvije provjere ne blokiraju "handler"
+func (app *application) wakeScheduler() {
+	select {
+	case app.schedulerWake <- struct{}{}:
+	default:
+	}
+}
+
+// "PUT /v1/movies/:id/schedule" - postavljanje vremenskog okvira objave: {"publish_at": "...", "unpublish_at": "..."}
+// vrijednosti se u potpunosti zamjenjuju ("null" ili izostavljeno polje uklanja ograničenje)
+func (app *application) updateMovieScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if app.ifMatchFailed(r, movieETag(movie)) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		PublishAt   *time.Time `json:"publish_at"`
+		UnpublishAt *time.Time `json:"unpublish_at"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	movie.PublishAt = input.PublishAt
+	movie.UnpublishAt = input.UnpublishAt
+
+	v := validator.New()
+	if data.ValidateSchedule(v, movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Movies.UpdateSchedule(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.wakeScheduler()
+	app.wakeOutbox()
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
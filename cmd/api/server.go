@@ -22,6 +22,10 @@ func (app *application) serve() error {
 		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
 	}
 
+	// "Shutdown()" ne prekida otvorene "stream" konekcije (pogledati "streamMovieEventsHandler()"), pa se one zatvaraju same
+	// u suprotnom bi "Shutdown()" čekao sve dok klijenti ne prekinu konekciju ili ne istekne rok od 30 sekundi
+	srv.RegisterOnShutdown(app.movieStream.close)
+
 	// ČITAVA LOGIKA:
 	// kad god primimo "SIGINT" ili "SIGTERM" signal, šaljemo instrukcije ka serveru da prestane sa prihvatanjem svih novih HTTP zahtjeva
 	// svi zahtjevi koji su trenutno "in-flight" imaju period od 30 sekundi da se završe prije nego što se aplikacija izgasi
@@ -30,6 +34,12 @@ func (app *application) serve() error {
 	// koristićemo ga za primanje svih grešaka koje vraća "Shutdown" funkcija
 	shutdownError := make(chan error)
 
+	// pozadinski poslovi ("scheduler",...) rade dok se server ne ugasi
+	jobs, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	app.startJobs(jobs)
+
 	// pokretanje "goroutine"-a u pozadini:
 	go func() {
 		// kreiranje "quit" kanala koji nosi "os.Signal" vrijednosti:
@@ -56,7 +66,19 @@ func (app *application) serve() error {
 		// "Shutdown()" će vratiti "nil" ukoliko je "graceful shutdown" prošao uspješno ili sa potencijalnu grešku
 		// greška može da se javi tokom zatvaranja "listener"-a ili zato što se "shutdown" nije završio u roku od 30 sekundi
 		// vrijednsot greške možemo da proslijedimo ka "shutdownError" kanalu
-		shutdownError <- srv.Shutdown(ctx)
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+
+		// nakon što su svi zahtjevi obrađeni, zaustavljaju se pozadinski poslovi i čeka se da se završe
+		// (uključujući i "goroutine"-e pokrenute preko "background()" helper-a, recimo slanje email-ova)
+		stopJobs()
+		app.logger.Info("completing background tasks", "addr", srv.Addr)
+		app.wg.Wait()
+
+		shutdownError <- nil
 	}()
 
 	app.logger.Info("starting server", "addr", srv.Addr, "env", app.config.env)
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/http"
+)
+
+// jedna stranica sličnih filmova u kešu
+type similarMoviesPage struct {
+	movies   []*data.SimilarMovie
+	metadata data.Metadata
+}
+
+// "GET /v1/movies/:id/similar" - filmovi slični zadatom filmu (pogledati "MovieModel.GetSimilar()"), uz paginaciju
+//
+// rezultati se keširaju po filmu - ključ sadrži verziju filma, pa izmjena filma automatski poništava njegove unose,
+// dok se izmjene ostalih filmova (naslov, žanrovi,...) pojavljuju nakon isteka keša
+// vidljivost filmova iz keširane stranice se ipak provjerava na svaki zahtjev, kako se ne bi prikazali arhivirani ili obrisani filmovi
+func (app *application) listSimilarMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters := data.Filters{
+		Page:     app.readInt(qs, "page", 1, v),
+		PageSize: app.readInt(qs, "page_size", 20, v),
+		// rezultati se uvijek sortiraju po sličnosti
+		Sort:         "similarity",
+		SortSafeList: []string{"similarity"},
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.movieVisible(r, movie) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// urednici vide i neobjavljene filmove, pa imaju zasebne unose u kešu
+	publishedOnly := !app.canEditMovies(r)
+	key := fmt.Sprintf("%d:%d:%t:%d:%d", movie.ID, movie.Version, publishedOnly, filters.Page, filters.PageSize)
+
+	page, ok := app.similarCache.Get(key)
+	if ok {
+		page.movies, err = app.visibleSimilarMovies(page.movies, publishedOnly)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	} else {
+		movies, metadata, err := app.models.Movies.GetSimilar(movie, publishedOnly, filters)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		page = similarMoviesPage{movies: movies, metadata: metadata}
+		app.similarCache.Set(key, page)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": page.movies, "metadata": page.metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "visibleSimilarMovies()" izbacuje iz keširane stranice filmove koji više nisu vidljivi
+// vraća se nova lista - keširana lista se dijeli između zahtjeva, pa se ne mijenja
+// "metadata" ostaje iz keša, pa broj zapisa može kratko da bude veći od stvarnog
+func (app *application) visibleSimilarMovies(movies []*data.SimilarMovie, publishedOnly bool) ([]*data.SimilarMovie, error) {
+	if len(movies) == 0 {
+		return movies, nil
+	}
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	visible, err := app.models.Movies.VisibleIDs(ids, publishedOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]*data.SimilarMovie, 0, len(movies))
+	for _, movie := range movies {
+		if visible[movie.ID] {
+			filtered = append(filtered, movie)
+		}
+	}
+
+	return filtered, nil
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"greenlight.lazarmrkic.com/internal/data"
+	"greenlight.lazarmrkic.com/internal/events"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// razmak između "heartbeat" komentara - oni održavaju konekciju otvorenom kroz "proxy"-je koji zatvaraju neaktivne konekcije
+	streamHeartbeatInterval = 15 * time.Second
+	// broj događaja koji mogu da čekaju na slanje jednom klijentu
+	// klijent koji zaostane više od toga se isključuje i nastavlja preko "Last-Event-ID" header-a
+	streamBufferSize = 64
+	// broj događaja koji se čitaju iz baze u jednom upitu (prilikom nastavka ili nakon obavještenja)
+	streamBatchSize = 100
+	// pauza prije ponovnog pokretanja "listener"-a nakon neuspjelog pokretanja - svaki naredni pokušaj čeka duplo duže
+	streamListenMinBackoff = time.Second
+	streamListenMaxBackoff = time.Minute
+)
+
+// "movieStream" prosljeđuje događaje iz "movie_events" tabele svim otvorenim "GET /v1/events/movies" konekcijama
+// događaje čita "runMovieEventListener()" posao, nakon "NOTIFY" obavještenja
+type movieStream struct {
+	mu          sync.Mutex
+	subscribers map[chan *data.MovieEvent]struct{}
+	closed      bool
+}
+
+func newMovieStream() *movieStream {
+	return &movieStream{subscribers: make(map[chan *data.MovieEvent]struct{})}
+}
+
+// "subscribe()" vraća kanal na koji stižu novi događaji
+// kanal se zatvara kada se "stream" ugasi ili kada klijent zaostane (pogledati "broadcast()")
+func (s *movieStream) subscribe() chan *data.MovieEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := make(chan *data.MovieEvent, streamBufferSize)
+
+	if s.closed {
+		close(ch)
+		return ch
+	}
+
+	s.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (s *movieStream) unsubscribe(ch chan *data.MovieEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscribers[ch]; ok {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// "broadcast()" ne blokira - kanal klijenta čiji je "buffer" pun se zatvara
+func (s *movieStream) broadcast(event *data.MovieEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// "close()" zatvara sve otvorene konekcije i odbija nove - poziva se prilikom gašenja servera (pogledati "serve()")
+// bez toga bi "Shutdown()" čekao da klijenti sami zatvore konekcije
+func (s *movieStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	for ch := range s.subscribers {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// "runMovieEventListener()" osluškuje "movie_events" kanal i prosljeđuje nove događaje preko "app.movieStream"
+// obavještenje služi samo kao signal - događaji se uvijek čitaju iz baze, nakon poslednjeg proslijeđenog događaja,
+// pa se ne gube ni događaji upisani dok je konekcija bila prekinuta ("pq.Listener" se sam ponovo povezuje)
+// posao takođe briše događaje starije od "data.MovieEventsRetention"
+func (app *application) runMovieEventListener(ctx context.Context) {
+	listener := pq.NewListener(app.config.db.dsn, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "movie-events")
+		}
+	})
+	defer listener.Close()
+
+	// "Listen()" čeka dok se konekcija ne uspostavi, pa se "listener" zatvara čim se "ctx" otkaže (inače bi blokirao gašenje)
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	lastID, ok := app.startMovieEventListener(ctx, listener)
+	if !ok {
+		return
+	}
+
+	// "Ping()" provjerava konekciju kada obavještenja ne stižu duže vrijeme (prekinuta konekcija se inače ne primijeti odmah)
+	ping := time.NewTicker(time.Minute)
+	defer ping.Stop()
+
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		// "nil" obavještenje znači da je konekcija ponovo uspostavljena - događaji se svakako čitaju od "lastID"
+		case <-listener.Notify:
+			lastID = app.forwardMovieEvents(lastID)
+		case <-ping.C:
+			go listener.Ping()
+		case <-cleanup.C:
+			deleted, err := app.models.MovieEvents.DeleteExpired()
+			if err != nil {
+				app.logger.Error(err.Error(), "job", "movie-events")
+				continue
+			}
+			if deleted > 0 {
+				app.logger.Info("deleted expired movie events", "deleted", deleted)
+			}
+		}
+	}
+}
+
+// "startMovieEventListener()" počinje osluškivanje kanala i vraća "id" poslednjeg upisanog događaja
+// neuspješan pokušaj (recimo, baza nije dostupna prilikom pokretanja) se ponavlja uz rastuću pauzu, sve dok se "ctx" ne otkaže
+// "false" se vraća samo ukoliko je "ctx" otkazan
+func (app *application) startMovieEventListener(ctx context.Context, listener *pq.Listener) (int64, bool) {
+	backoff := streamListenMinBackoff
+
+	for {
+		err := listener.Listen(data.MovieEventsChannel)
+		// kanal ostaje registrovan i nakon pokušaja u kom "LatestID()" nije uspio
+		if err == nil || errors.Is(err, pq.ErrChannelAlreadyOpen) {
+			var lastID int64
+
+			lastID, err = app.models.MovieEvents.LatestID()
+			if err == nil {
+				return lastID, true
+			}
+		}
+
+		if ctx.Err() != nil {
+			return 0, false
+		}
+
+		app.logger.Error(err.Error(), "job", "movie-events", "retry_in", backoff.String())
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, false
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, streamListenMaxBackoff)
+	}
+}
+
+// "forwardMovieEvents()" prosljeđuje sve događaje upisane nakon "lastID" i vraća "id" poslednjeg proslijeđenog događaja
+func (app *application) forwardMovieEvents(lastID int64) int64 {
+	for {
+		movieEvents, err := app.models.MovieEvents.GetSince(lastID, streamBatchSize)
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "movie-events")
+			return lastID
+		}
+
+		for _, event := range movieEvents {
+			app.movieStream.broadcast(event)
+			lastID = event.ID
+		}
+
+		if len(movieEvents) < streamBatchSize {
+			return lastID
+		}
+	}
+}
+
+// "GET /v1/events/movies" - "Server-Sent Events" stream izmjena filmova ("movie.created", "movie.updated", "movie.deleted")
+// "id" svakog događaja se može poslati u "Last-Event-ID" header-u (što "EventSource" radi sam prilikom ponovnog povezivanja),
+// pa klijent dobija i sve događaje koje je propustio (najviše "data.MovieEventsRetention" unazad)
+//
+// korisnici koji ne mogu da uređuju filmove dobijaju samo događaje objavljenih filmova (i sva brisanja)
+// film koji za njih prestane da bude vidljiv (arhiviran ili povučen iz objave) dobijaju kao "movie.deleted" događaj
+func (app *application) streamMovieEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var lastID int64
+
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			app.badRequestResponse(w, r, errors.New("invalid Last-Event-ID header"))
+			return
+		}
+		lastID = id
+	}
+
+	// "stream" traje duže od "WriteTimeout" vrijednosti servera, pa se rok za upis ukida samo za ovaj zahtjev
+	rc := http.NewResponseController(w)
+
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// pretplata se otvara PRIJE čitanja propuštenih događaja, kako se ne bi izgubili događaji upisani u međuvremenu
+	// događaji koji stignu na oba načina se preskaču preko "lastID"
+	ch := app.movieStream.subscribe()
+	defer app.movieStream.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// "retry" govori "EventSource" klijentu koliko da čeka prije ponovnog povezivanja
+	_, err = fmt.Fprint(w, "retry: 5000\n\n")
+	if err != nil {
+		return
+	}
+
+	if r.Header.Get("Last-Event-ID") != "" {
+		for {
+			movieEvents, err := app.models.MovieEvents.GetSince(lastID, streamBatchSize)
+			if err != nil {
+				app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+				return
+			}
+
+			for _, event := range movieEvents {
+				lastID = event.ID
+
+				err = app.writeMovieEvent(w, r, event)
+				if err != nil {
+					return
+				}
+			}
+
+			if len(movieEvents) < streamBatchSize {
+				break
+			}
+		}
+	}
+
+	err = rc.Flush()
+	if err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(streamHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-ch:
+			// "stream" je ugašen ili klijent previše zaostaje
+			if !ok {
+				return
+			}
+			if event.ID <= lastID {
+				continue
+			}
+			lastID = event.ID
+
+			err = app.writeMovieEvent(w, r, event)
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// "writeMovieEvent()" upisuje jedan SSE događaj (bez "flush"-a)
+// filtrira se stanje filma NAKON izmjene: izmjena filma koji trenutni korisnik ne vidi se šalje kao uklanjanje ("movie.deleted" sa samo "id"-em),
+// kako bi klijent uklonio film koji je možda ranije prikazao
+// novi film koji korisnik ne vidi nikada nije bio vidljiv, pa se njegov događaj preskače
+func (app *application) writeMovieEvent(w http.ResponseWriter, r *http.Request, event *data.MovieEvent) error {
+	eventType := event.Type
+	var details any = event.Data
+
+	if event.Type != "movie.deleted" {
+		var movie data.Movie
+
+		err := json.Unmarshal(event.Data, &movie)
+		if err != nil {
+			return err
+		}
+
+		if !app.movieVisible(r, &movie) {
+			if event.Type == "movie.created" {
+				return nil
+			}
+
+			eventType = "movie.deleted"
+			details = map[string]int64{"id": event.MovieID}
+		}
+	}
+
+	js, err := json.Marshal(events.Event{Type: eventType, MovieID: event.MovieID, OccurredAt: event.CreatedAt, Data: details})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, eventType, js)
+	return err
+}
@@ -5,7 +5,6 @@ import (
 	validator "greenlight.lazarmrkic.com/internal"
 	"greenlight.lazarmrkic.com/internal/data"
 	"net/http"
-	"time"
 )
 
 // "registerUserHandler" treba da kreira novi "User" struct, koji sadrži podatke poslate na "endpoint"
@@ -65,34 +64,11 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// nakon što se korisnik kreira u bazi, za njega treba generisati "activation token"
-	token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-	// slanje "welcome" email-a može značajno da uveća latenciju za "request/response" ciklus
-	// zbog toga, slanje email-a treba izdvojiti u zasebnu "gouroutine"-u
-	// ona će da se izvršava PARALELNO sa pratećim kodom
-	// odnosno, mogli bismo da vratimo HTTP response klijentu bez čekanja na slanje email-a
-	// pozadinski "gorotuine" će da se izvršava još dugo nakon vraćanja JSON-a
-	//
-	// pokretanje "goroutine" - koja pokreće anonimnu funkciju koja šalje "welcome" email:
-	app.background(func() {
-		// trenutno postoji više podataka koje trebamo da proslijedimo u "email" šablone
-		// zbog toga ćemo da kreiramo mapu koja će da čuva ove podatke
-		data := map[string]any{
-			"activationToken": token.Plaintext,
-			"userID":          user.ID,
-		}
-
-		// slanje "welcome" mejla, a mapa se prosljeđuje kao "dynamic" podatak:
-		err = app.mailer.Send(user.Email, "user_welcome.tmpl", data)
-		if err != nil {
-			app.logger.Error(err.Error())
-		}
-	})
+	// "welcome" email (skupa sa "activation token"-om) ne šaljemo direktno iz "handler"-a
+	// "UserModel.Insert()" je u istoj transakciji upisao "user.registered" poruku u "outbox",
+	// a "dispatcher" je obrađuje u pozadini (pogledati "sendWelcomeEmail()") i ponavlja slanje ukoliko ne uspije
+	// tako se email ne gubi čak ni ukoliko se proces ugasi odmah nakon odgovora
+	app.wakeOutbox()
 
 	// ispisivanje JSON odgovora koji sadrži podatke o korisniku, skupa sa "202 Accepted" status kodom
 	// to znači da smo "request" prihvatili za procesuiranje i da se ono još uvijek nije završilo
@@ -138,12 +114,10 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// ažuriranje "Activated" statusa za datog korisnika:
-	user.Activated = true
-
-	// čuvanje ažuriranog korisnika u bazi i provjera da li postoje neki "edit" konflikti
+	// ažuriranje "Activated" statusa za datog korisnika i provjera da li postoje neki "edit" konflikti
 	// koristi se isti pristup kao za "movie" podatke
-	err = app.models.Users.Update(user)
+	// aktivacioni tokeni se brišu, a "user.activated" poruka upisuje u "outbox" unutar iste transakcije
+	err = app.models.Users.Activate(user)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -154,12 +128,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// ukoliko je sve prošlo kako treba, onda brišemo sve aktivacione tokene za datog korisnika:
-	err = app.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
+	app.wakeOutbox()
 
 	// slanje JSON-a sa ažuriranim podacima o korisniku:
 	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
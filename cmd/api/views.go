@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// pregledi se upisuju u "movie_views" u grupama, kako svaki "GET /v1/movies/:id" ne bi zauzimao konekciju ka bazi
+// grupa se upisuje kada se popuni ili najkasnije nakon "viewsFlushInterval"-a
+// "viewsMaxBuffered" ograničava memoriju ukoliko baza duže vrijeme nije dostupna
+const (
+	viewsBatchSize     = 500
+	viewsFlushInterval = time.Second
+	viewsMaxBuffered   = 100_000
+)
+
+// pregledi filmova koji još nisu upisani u "movie_views" (pogledati "runViewWriter()")
+type viewBuffer struct {
+	mu      sync.Mutex
+	views   []data.MovieView
+	dropped int64
+}
+
+// "add()" dodaje pregled i vraća broj pregleda koji čekaju na upis
+func (b *viewBuffer) add(view data.MovieView) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.views) >= viewsMaxBuffered {
+		b.dropped++
+		return len(b.views)
+	}
+
+	b.views = append(b.views, view)
+	return len(b.views)
+}
+
+// "drain()" vraća najviše "viewsBatchSize" najstarijih pregleda i uklanja ih iz bafera
+func (b *viewBuffer) drain() []data.MovieView {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := min(len(b.views), viewsBatchSize)
+	batch := make([]data.MovieView, n)
+	copy(batch, b.views[:n])
+	b.views = b.views[n:]
+
+	return batch
+}
+
+// "requeue()" vraća neupisanu grupu na početak bafera, kako bi se upisala prilikom narednog pokušaja
+func (b *viewBuffer) requeue(batch []data.MovieView) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	free := viewsMaxBuffered - len(b.views)
+	if free < len(batch) {
+		b.dropped += int64(len(batch) - free)
+		batch = batch[len(batch)-max(free, 0):]
+	}
+
+	b.views = append(batch, b.views...)
+}
+
+// "takeDropped()" vraća broj pregleda odbačenih zbog punog bafera od prethodnog poziva
+func (b *viewBuffer) takeDropped() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dropped := b.dropped
+	b.dropped = 0
+
+	return dropped
+}
+
+// "recordMovieView()" samo dodaje pregled u bafer, pa pregled ne usporava odgovor niti zauzima konekciju ka bazi
+// kada se grupa popuni, "writer" se budi i upisuje je bez čekanja na "viewsFlushInterval"
+func (app *application) recordMovieView(movieID int64) {
+	pending := app.views.add(data.MovieView{MovieID: movieID, ViewedAt: time.Now()})
+
+	if pending >= viewsBatchSize {
+		select {
+		case app.viewsWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// "runViewWriter()" upisuje preglede iz bafera u "movie_views" (pogledati "ViewModel.Record()")
+// grupa koja nije upisana vraća se u bafer i upisuje prilikom narednog pokušaja
+// nakon otkazivanja "ctx"-a upisuju se svi preostali pregledi, prije gašenja aplikacije
+func (app *application) runViewWriter(ctx context.Context) {
+	ticker := time.NewTicker(viewsFlushInterval)
+	defer ticker.Stop()
+
+	// vraća "false" kada je bafer prazan ili upis nije uspio
+	flush := func(ctx context.Context) bool {
+		if dropped := app.views.takeDropped(); dropped > 0 {
+			app.logger.Error("movie view buffer is full", "job", "view-writer", "dropped", dropped)
+		}
+
+		batch := app.views.drain()
+		if len(batch) == 0 {
+			return false
+		}
+
+		err := app.models.Views.Record(ctx, batch)
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "view-writer", "views", len(batch))
+			app.views.requeue(batch)
+			return false
+		}
+
+		return true
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			for flush(final) {
+			}
+			cancel()
+			return
+		case <-ticker.C:
+		case <-app.viewsWake:
+		}
+
+		for flush(ctx) {
+		}
+	}
+}
+
+// "runViewAggregation()" periodično sabira pojedinačne preglede u broj pregleda po satu (pogledati "ViewModel.Aggregate()")
+// i jednom na sat briše statistiku stariju od "data.ViewStatsRetention"
+// nakon otkazivanja "ctx"-a pregledi se sabiraju još jednom, prije gašenja aplikacije
+// pregledi koji nisu stigli da budu obrađeni ostaju u "movie_views" tabeli i uračunavaju se nakon narednog pokretanja
+func (app *application) runViewAggregation(ctx context.Context) {
+	ticker := time.NewTicker(app.config.jobs.viewsInterval)
+	defer ticker.Stop()
+
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	aggregate := func(ctx context.Context) {
+		_, err := app.models.Views.Aggregate(ctx)
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "views")
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			aggregate(final)
+			cancel()
+			return
+		case <-ticker.C:
+			aggregate(ctx)
+		case <-cleanup.C:
+			deleted, err := app.models.Views.DeleteExpired(ctx)
+			if err != nil {
+				app.logger.Error(err.Error(), "job", "views")
+				continue
+			}
+			if deleted > 0 {
+				app.logger.Info("deleted expired view statistics", "deleted", deleted)
+			}
+		}
+	}
+}
+
+// "GET /v1/movies/trending?window=24h|7d|30d" - najgledaniji filmovi unutar zadatog okvira (podrazumijevano "24h"), uz paginaciju
+func (app *application) listTrendingMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	window := app.readString(qs, "window", "24h")
+	_, ok := data.TrendingWindows[window]
+	v.Check(ok, "window", "must be one of 24h, 7d or 30d")
+
+	filters := data.Filters{
+		Page:     app.readInt(qs, "page", 1, v),
+		PageSize: app.readInt(qs, "page_size", 20, v),
+		// rezultati se uvijek sortiraju po broju pregleda
+		Sort:         "views",
+		SortSafeList: []string{"views"},
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, metadata, err := app.models.Views.GetTrending(window, !app.canEditMovies(r), filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "window": window, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	validator "greenlight.lazarmrkic.com/internal"
+	"greenlight.lazarmrkic.com/internal/data"
+	"greenlight.lazarmrkic.com/internal/events"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// broj isporuka koje se preuzimaju u jednom prolazu
+	webhookBatchSize = 10
+	// najduži razmak između dvije provjere reda isporuka (nove isporuke bude "deliverer" preko "wakeWebhooks()")
+	webhookPollInterval = 5 * time.Second
+	// koliko često se brišu isporuke starije od "data.WebhookDeliveriesRetention"
+	webhookCleanupInterval = time.Hour
+)
+
+// tijelo isporuke - isto za sve tipove događaja
+type webhookPayload struct {
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// "enqueueWebhookEvent()" upisuje isporuke događaja za sve aktivne pretplate
+// poziva ga "outbox dispatcher", pa se greška vraća kako bi poruka bila ponovo obrađena
+func (app *application) enqueueWebhookEvent(event events.Event) error {
+	details := event.Data
+	if details == nil {
+		details = map[string]int64{"id": event.MovieID}
+	}
+
+	payload, err := json.Marshal(webhookPayload{Event: event.Type, OccurredAt: event.OccurredAt, Data: details})
+	if err != nil {
+		return err
+	}
+
+	count, err := app.models.Webhooks.Enqueue(event.Type, payload)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		app.wakeWebhooks()
+	}
+
+	return nil
+}
+
+// "wakeWebhooks()" obavještava "deliverer" da u redu postoje nove isporuke
+func (app *application) wakeWebhooks() {
+	select {
+	case app.webhooksWake <- struct{}{}:
+	default:
+	}
+}
+
+// "runWebhookDeliveries()" šalje isporuke iz reda (pogledati "WebhookModel.ClaimDue()" i "RecordAttempt()")
+// dok u redu ima isporuka koje čekaju, preuzimaju se bez pauze, a u suprotnom se čeka najduže "webhookPollInterval"
+// posao takođe briše završene isporuke starije od "data.WebhookDeliveriesRetention"
+func (app *application) runWebhookDeliveries(ctx context.Context) {
+	cleanup := time.NewTicker(webhookCleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		// brisanje se provjerava na početku svakog prolaza, kako se ne bi odlagalo dok u redu stalno ima isporuka
+		select {
+		case <-cleanup.C:
+			deleted, err := app.models.Webhooks.DeleteExpiredDeliveries()
+			if err != nil {
+				app.logger.Error(err.Error(), "job", "webhooks")
+			} else if deleted > 0 {
+				app.logger.Info("deleted expired webhook deliveries", "deleted", deleted)
+			}
+		default:
+		}
+
+		deliveries, err := app.models.Webhooks.ClaimDue(webhookBatchSize)
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "webhooks")
+		}
+
+		for _, delivery := range deliveries {
+			app.deliverWebhook(ctx, delivery)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if len(deliveries) == webhookBatchSize {
+			continue
+		}
+
+		timer := time.NewTimer(webhookPollInterval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-app.webhooksWake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// "deliverWebhook()" šalje jednu isporuku i bilježi rezultat
+// uspješnom se smatra isključivo odgovor sa "2xx" statusom (preusmjerenja se ne prate)
+//
+// isporuka se potpisuje tajnom pretplate (pogledati "data.SignWebhook()"), a "X-Greenlight-Delivery" je isti za sve pokušaje,
+// pa primalac može da prepozna isporuku koju je već obradio
+func (app *application) deliverWebhook(ctx context.Context, delivery *data.WebhookDelivery) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		app.recordWebhookAttempt(delivery, 0, err)
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Greenlight-Webhooks/"+version)
+	req.Header.Set("X-Greenlight-Event", delivery.Event)
+	req.Header.Set("X-Greenlight-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Greenlight-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Greenlight-Signature", data.SignWebhook(delivery.Secret, timestamp, delivery.Payload))
+
+	status := 0
+
+	res, err := app.webhookClient.Do(req)
+	if err == nil {
+		status = res.StatusCode
+		// tijelo odgovora nije bitno, ali se čita kako bi konekcija mogla ponovo da se koristi
+		io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+		res.Body.Close()
+
+		if status < 200 || status > 299 {
+			err = fmt.Errorf("unexpected response status %d", status)
+		}
+	}
+
+	// aplikacija se gasi - isporuka ostaje rezervisana i šalje se ponovo nakon isteka rezervacije
+	if ctx.Err() != nil {
+		return
+	}
+
+	app.recordWebhookAttempt(delivery, status, err)
+}
+
+func (app *application) recordWebhookAttempt(delivery *data.WebhookDelivery, status int, attemptErr error) {
+	err := app.models.Webhooks.RecordAttempt(delivery, status, attemptErr)
+	if err != nil {
+		app.logger.Error(err.Error(), "job", "webhooks", "delivery_id", delivery.ID)
+		return
+	}
+
+	if delivery.Status == data.DeliveryFailed {
+		app.logger.Warn("webhook delivery failed", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID, "error", delivery.LastError)
+	}
+}
+
+// "POST /v1/webhooks" - nova pretplata: {"url": "...", "events": ["movie.created"], "secret": "..."}
+// ukoliko "secret" nije poslat, generiše se nasumična tajna - ona se vraća samo u ovom odgovoru
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL    string   `json:"url"`
+		Secret string   `json:"secret"`
+		Events []string `json:"events"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhook := &data.Webhook{
+		URL:    input.URL,
+		Secret: input.Secret,
+		Events: input.Events,
+	}
+
+	v := validator.New()
+
+	if data.ValidateWebhook(v, webhook); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret, err = data.GenerateWebhookSecret()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.models.Webhooks.Insert(webhook, app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/webhooks/%d", webhook.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"webhook": webhook}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := app.models.Webhooks.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"webhooks": webhooks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"webhook": webhook}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "PATCH /v1/webhooks/:id" - izmjena pretplate ("url", "events", "secret", "active")
+// {"active": true} ponovo uključuje pretplatu koja je automatski isključena nakon uzastopnih grešaka
+func (app *application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		URL    *string  `json:"url"`
+		Secret *string  `json:"secret"`
+		Events []string `json:"events"`
+		Active *bool    `json:"active"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if input.URL != nil {
+		webhook.URL = *input.URL
+	}
+	if input.Secret != nil {
+		v.Check(*input.Secret != "", "secret", "must not be empty")
+		webhook.Secret = *input.Secret
+	}
+	if input.Events != nil {
+		webhook.Events = input.Events
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+
+	if data.ValidateWebhook(v, webhook); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Webhooks.Update(webhook)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// tajna se ne vraća nakon kreiranja
+	webhook.Secret = ""
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"webhook": webhook}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Webhooks.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "webhook successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "POST /v1/webhooks/:id/ping" - probna isporuka ("webhook.ping" događaj), za provjeru URL-a i potpisa kod primaoca
+func (app *application) pingWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	payload, err := json.Marshal(webhookPayload{Event: "webhook.ping", OccurredAt: time.Now(), Data: map[string]int64{"id": webhook.ID}})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	delivery, err := app.models.Webhooks.EnqueueFor(webhook.ID, "webhook.ping", payload)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.wakeWebhooks()
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"delivery": delivery}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// "GET /v1/webhooks/:id/deliveries" - log isporuka, od najnovije, uz paginaciju
+// "status" parametar ograničava log na isporuke sa tim statusom ("pending", "succeeded", "failed")
+func (app *application) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	status := app.readString(qs, "status", "")
+	v.Check(status == "" || validator.PermittedValue(status, data.DeliveryPending, data.DeliverySucceeded, data.DeliveryFailed), "status", "must be one of pending, succeeded or failed")
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "page_size", 20, v),
+		Sort:         "-id",
+		SortSafeList: []string{"-id"},
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	webhook, ok := app.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, metadata, err := app.models.Webhooks.GetDeliveries(webhook.ID, status, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"deliveries": deliveries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// učitavanje pretplate iz ":id" parametra
+// ukoliko pretplata nije pronađena, odgovor je već poslat i vraća se "false"
+func (app *application) readWebhook(w http.ResponseWriter, r *http.Request) (*data.Webhook, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	webhook, err := app.models.Webhooks.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return webhook, true
+}
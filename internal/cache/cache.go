@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// jednostavan "in-process" keš sa ograničenim trajanjem unosa ("TTL") i ograničenim brojem unosa
+// bezbjedan je za "concurrent use", jer se svaki pristup mapi štiti "mutex"-om
+type Cache[K comparable, V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[K]entry[V]
+}
+
+type entry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// "New()" kreira keš u kom svaki unos važi "ttl" vremena
+// kada se dostigne "maxEntries", prvo se uklanjaju istekli unosi, a ukoliko ih nema - čitav keš se prazni
+func New[K comparable, V any](ttl time.Duration, maxEntries int) *Cache[K, V] {
+	return &Cache[K, V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[K]entry[V]),
+	}
+}
+
+// "Get()" vraća vrijednost za dati ključ, ukoliko ona postoji i nije istekla
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		var zero V
+		return zero, false
+	}
+
+	return e.value, true
+}
+
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+
+	c.entries[key] = entry[V]{value: value, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+// "Clear()" uklanja sve unose (recimo, nakon izmjene podataka od kojih zavise svi unosi)
+func (c *Cache[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[K]entry[V])
+}
+
+// poziva se samo dok je "mutex" zaključan
+func (c *Cache[K, V]) evict() {
+	now := time.Now()
+
+	for key, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+
+	if len(c.entries) >= c.maxEntries {
+		c.entries = make(map[K]entry[V])
+	}
+}
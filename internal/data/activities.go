@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// tipovi aktivnosti koje se prikazuju pratiocima korisnika
+// korisničke liste filmova za sada ne postoje - kada budu dodate, njihove izmjene treba upisivati kao novi tip aktivnosti
+const (
+	ActivityRating  = "rating"
+	ActivityComment = "comment"
+)
+
+// jedna aktivnost korisnika
+// "Data" zavisi od tipa aktivnosti: {"rating": 8} za ocjenu, {"parent_id": 12} za odgovor na komentar,...
+type Activity struct {
+	ID         int64           `json:"id"`
+	UserID     int64           `json:"user_id"`
+	UserName   string          `json:"user_name"`
+	Type       string          `json:"type"`
+	MovieID    *int64          `json:"movie_id,omitempty"`
+	MovieTitle string          `json:"movie_title,omitempty"`
+	CommentID  *int64          `json:"comment_id,omitempty"`
+	Data       json.RawMessage `json:"data"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// "ActivityModel" služi za čitanje "activities" tabele
+// aktivnosti upisuju modeli same akcije (preko "insertActivity()"), unutar svoje transakcije
+type ActivityModel struct {
+	DB *sql.DB
+}
+
+// "insertActivity()" upisuje aktivnost unutar postojeće transakcije
+// ukoliko akcija ne uspije (i transakcija se poništi), aktivnost se ne pojavljuje u "feed"-u
+func insertActivity(ctx context.Context, tx *sql.Tx, activity *Activity) error {
+	if activity.Data == nil {
+		activity.Data = json.RawMessage(`{}`)
+	}
+
+	query := `
+        INSERT INTO activities (user_id, type, movie_id, comment_id, data)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at`
+
+	args := []any{activity.UserID, activity.Type, activity.MovieID, activity.CommentID, []byte(activity.Data)}
+
+	return tx.QueryRowContext(ctx, query, args...).Scan(&activity.ID, &activity.CreatedAt)
+}
+
+// "GetFeed()" vraća aktivnosti korisnika koje prati korisnik "userID", od najnovije, uz paginaciju
+// aktivnosti vezane za neobjavljene filmove i obrisane komentare se izostavljaju
+func (m ActivityModel) GetFeed(userID int64, filters Filters) ([]*Activity, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), a.id, a.user_id, u.name, a.type, a.movie_id, COALESCE(m.title, ''), a.comment_id, a.data, a.created_at
+        FROM activities a
+        INNER JOIN follows f ON f.followee_id = a.user_id AND f.follower_id = $1
+        INNER JOIN users u ON u.id = a.user_id
+        LEFT JOIN movies m ON m.id = a.movie_id
+        LEFT JOIN comments c ON c.id = a.comment_id
+        WHERE (a.movie_id IS NULL OR %s)
+        AND c.deleted_at IS NULL
+        ORDER BY a.created_at DESC, a.id DESC
+        LIMIT $2 OFFSET $3`, moviePublishedCondition)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	activities := []*Activity{}
+
+	for rows.Next() {
+		var activity Activity
+
+		err := rows.Scan(
+			&totalRecords,
+			&activity.ID,
+			&activity.UserID,
+			&activity.UserName,
+			&activity.Type,
+			&activity.MovieID,
+			&activity.MovieTitle,
+			&activity.CommentID,
+			&activity.Data,
+			&activity.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		activities = append(activities, &activity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return activities, metadata, nil
+}
@@ -0,0 +1,523 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	validator "greenlight.lazarmrkic.com/internal"
+	"time"
+)
+
+// razrješenja prijava: "dismiss" zatvara prijave bez izmjene komentara, a "remove" briše komentar
+var CommentResolutions = []string{"dismiss", "remove"}
+
+// najveći broj odgovora koji se vraća uz jednu nit (najstariji odgovori, na svim nivoima)
+// ukupan broj odgovora se nalazi u "ReplyCount" polju niti
+const CommentRepliesPerThread = 50
+
+// komentar na film
+// "Replies" sadrži odgovore (i odgovore na njih), sortirane od najstarijeg - najviše "CommentRepliesPerThread" po niti
+// "ReplyCount" je ukupan broj odgovora u niti (postavlja se samo za niti)
+// tekst obrisanog komentara se ne vraća, ali komentar ostaje u niti kako bi odgovori imali kontekst
+type Comment struct {
+	ID         int64      `json:"id"`
+	MovieID    int64      `json:"movie_id"`
+	ParentID   *int64     `json:"parent_id,omitempty"`
+	UserID     int64      `json:"user_id"`
+	UserName   string     `json:"user_name"`
+	Body       string     `json:"body"`
+	CreatedAt  time.Time  `json:"created_at"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	Version    int32      `json:"version"`
+	Replies    []*Comment `json:"replies,omitempty"`
+	ReplyCount int        `json:"reply_count,omitempty"`
+}
+
+// prethodna verzija teksta komentara
+// "CreatedAt" je trenutak kada je tekst napisan, a "ReplacedAt" trenutak kada je zamijenjen novim
+type CommentRevision struct {
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// prijava komentara od strane korisnika
+type CommentReport struct {
+	CommentID int64     `json:"comment_id"`
+	UserID    int64     `json:"user_id"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// komentar unutar reda za moderaciju, skupa sa otvorenim prijavama
+type ReportedComment struct {
+	Comment
+	Reports         int       `json:"reports"`
+	Reasons         []string  `json:"reasons"`
+	FirstReportedAt time.Time `json:"first_reported_at"`
+}
+
+func ValidateComment(v *validator.Validator, comment *Comment) {
+	v.Check(comment.Body != "", "body", "must be provided")
+	v.Check(len(comment.Body) <= 5000, "body", "must not be more than 5000 bytes long")
+}
+
+func ValidateCommentReport(v *validator.Validator, report *CommentReport) {
+	v.Check(report.Reason != "", "reason", "must be provided")
+	v.Check(len(report.Reason) <= 500, "reason", "must not be more than 500 bytes long")
+}
+
+// "CommentModel" služi za interakciju sa "comments", "comment_revisions" i "comment_reports" tabelama
+type CommentModel struct {
+	DB *sql.DB
+}
+
+// kolone komentara, u redoslijedu koji očekuje "scanComment()"
+// tekst obrisanog komentara se ne čita iz baze
+const commentColumns = `c.id, c.movie_id, c.parent_id, c.user_id, u.name,
+        CASE WHEN c.deleted_at IS NULL THEN c.body ELSE '' END,
+        c.created_at, c.edited_at, c.deleted_at, c.version`
+
+func scanComment(comment *Comment, dest ...any) []any {
+	return append(dest,
+		&comment.ID,
+		&comment.MovieID,
+		&comment.ParentID,
+		&comment.UserID,
+		&comment.UserName,
+		&comment.Body,
+		&comment.CreatedAt,
+		&comment.EditedAt,
+		&comment.DeletedAt,
+		&comment.Version,
+	)
+}
+
+// "Insert()" upisuje komentar i bilježi ga kao aktivnost korisnika (unutar iste transakcije)
+func (m CommentModel) Insert(comment *Comment) error {
+	query := `
+        INSERT INTO comments (movie_id, parent_id, user_id, body)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := []any{comment.MovieID, comment.ParentID, comment.UserID, comment.Body}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&comment.ID, &comment.CreatedAt, &comment.Version)
+	if err != nil {
+		return err
+	}
+
+	details := json.RawMessage(`{}`)
+	if comment.ParentID != nil {
+		details, err = json.Marshal(map[string]int64{"parent_id": *comment.ParentID})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = insertActivity(ctx, tx, &Activity{
+		UserID:    comment.UserID,
+		Type:      ActivityComment,
+		MovieID:   &comment.MovieID,
+		CommentID: &comment.ID,
+		Data:      details,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// "Get()" vraća komentar bez odgovora (i kada je obrisan)
+func (m CommentModel) Get(id int64) (*Comment, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM comments c
+        INNER JOIN users u ON u.id = c.user_id
+        WHERE c.id = $1`, commentColumns)
+
+	var comment Comment
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(scanComment(&comment)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &comment, nil
+}
+
+// "GetThreads()" vraća niti jednog filma (komentare bez "parent_id"), uz paginaciju
+// odgovori se učitavaju drugim upitom (rekurzivno, za sve niti sa stranice) i raspoređuju unutar "Replies" polja
+// velika nit ne smije da poveća odgovor bez ograničenja, pa se vraća samo "CommentRepliesPerThread" najstarijih odgovora
+func (m CommentModel) GetThreads(movieID int64, filters Filters) ([]*Comment, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), %s
+        FROM comments c
+        INNER JOIN users u ON u.id = c.user_id
+        WHERE c.movie_id = $1 AND c.parent_id IS NULL
+        ORDER BY c.%s %s, c.id ASC
+        LIMIT $2 OFFSET $3`, commentColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	threads := []*Comment{}
+
+	for rows.Next() {
+		var comment Comment
+
+		err := rows.Scan(scanComment(&comment, &totalRecords)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		threads = append(threads, &comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	err = m.loadReplies(ctx, threads)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return threads, metadata, nil
+}
+
+func (m CommentModel) loadReplies(ctx context.Context, threads []*Comment) error {
+	if len(threads) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(threads))
+	byID := make(map[int64]*Comment)
+
+	for i, thread := range threads {
+		ids[i] = thread.ID
+		byID[thread.ID] = thread
+	}
+
+	// "thread_id" prati nit kojoj odgovor pripada, a "position" redoslijed odgovora unutar niti
+	// odgovor je uvijek kreiran nakon roditelja, pa se sa prvih N odgovora uvijek vraćaju i njihovi roditelji
+	query := fmt.Sprintf(`
+        WITH RECURSIVE replies AS (
+            SELECT id, parent_id AS thread_id FROM comments WHERE parent_id = ANY($1)
+            UNION ALL
+            SELECT c.id, r.thread_id FROM comments c INNER JOIN replies r ON c.parent_id = r.id
+        ),
+        ranked AS (
+            SELECT replies.id, replies.thread_id,
+                row_number() OVER (PARTITION BY replies.thread_id ORDER BY c.created_at ASC, c.id ASC) AS position,
+                count(*) OVER (PARTITION BY replies.thread_id) AS total
+            FROM replies
+            INNER JOIN comments c ON c.id = replies.id
+        )
+        SELECT ranked.thread_id, ranked.total, %s
+        FROM ranked
+        INNER JOIN comments c ON c.id = ranked.id
+        INNER JOIN users u ON u.id = c.user_id
+        WHERE ranked.position <= $2
+        ORDER BY c.created_at ASC, c.id ASC`, commentColumns)
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), CommentRepliesPerThread)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var replies []*Comment
+
+	for rows.Next() {
+		var comment Comment
+		var threadID int64
+		var total int
+
+		err := rows.Scan(scanComment(&comment, &threadID, &total)...)
+		if err != nil {
+			return err
+		}
+
+		byID[threadID].ReplyCount = total
+
+		replies = append(replies, &comment)
+		byID[comment.ID] = &comment
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// roditelj se uvijek nalazi u mapi (nit sa stranice ili odgovor iz istog upita)
+	for _, reply := range replies {
+		parent := byID[*reply.ParentID]
+		parent.Replies = append(parent.Replies, reply)
+	}
+
+	return nil
+}
+
+// "Update()" mijenja tekst komentara, a prethodni tekst čuva u istoriji izmjena (unutar iste transakcije)
+// kao i "MovieModel.Update()", koristi "optimistic locking" preko "version" kolone
+func (m CommentModel) Update(comment *Comment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO comment_revisions (comment_id, body, created_at)
+        SELECT id, body, COALESCE(edited_at, created_at)
+        FROM comments
+        WHERE id = $1 AND version = $2 AND deleted_at IS NULL`
+
+	result, err := tx.ExecContext(ctx, query, comment.ID, comment.Version)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	query = `
+        UPDATE comments
+        SET body = $1, edited_at = NOW(), version = version + 1
+        WHERE id = $2 AND version = $3
+        RETURNING edited_at, version`
+
+	err = tx.QueryRowContext(ctx, query, comment.Body, comment.ID, comment.Version).Scan(&comment.EditedAt, &comment.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// "Delete()" označava komentar kao obrisan ("soft delete")
+// "ErrRecordNotFound" se vraća ukoliko je komentar već obrisan
+func (m CommentModel) Delete(id, deletedBy int64) error {
+	query := `
+        UPDATE comments
+        SET deleted_at = NOW(), deleted_by = $2, version = version + 1
+        WHERE id = $1 AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, deletedBy)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// "GetRevisions()" vraća prethodne verzije teksta komentara, od najnovije
+func (m CommentModel) GetRevisions(commentID int64) ([]*CommentRevision, error) {
+	query := `
+        SELECT body, created_at, replaced_at
+        FROM comment_revisions
+        WHERE comment_id = $1
+        ORDER BY id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*CommentRevision{}
+
+	for rows.Next() {
+		var revision CommentRevision
+
+		err := rows.Scan(&revision.Body, &revision.CreatedAt, &revision.ReplacedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// "Report()" upisuje prijavu komentara
+// ponovna prijava istog korisnika mijenja razlog i ponovo otvara prijavu ukoliko je ranije razriješena
+func (m CommentModel) Report(report *CommentReport) error {
+	query := `
+        INSERT INTO comment_reports (comment_id, user_id, reason)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (comment_id, user_id) DO UPDATE
+        SET reason = EXCLUDED.reason, created_at = NOW(), resolved_at = NULL, resolved_by = NULL, resolution = NULL
+        RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, report.CommentID, report.UserID, report.Reason).Scan(&report.CreatedAt)
+}
+
+// "GetReported()" vraća red za moderaciju - komentare sa otvorenim prijavama, od onih sa najviše prijava, uz paginaciju
+func (m CommentModel) GetReported(filters Filters) ([]*ReportedComment, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), %s, reports.count, reports.reasons, reports.first_reported_at
+        FROM (
+            SELECT comment_id, count(*) AS count, array_agg(reason ORDER BY created_at) AS reasons, min(created_at) AS first_reported_at
+            FROM comment_reports
+            WHERE resolved_at IS NULL
+            GROUP BY comment_id
+        ) AS reports
+        INNER JOIN comments c ON c.id = reports.comment_id
+        INNER JOIN users u ON u.id = c.user_id
+        ORDER BY reports.count DESC, reports.first_reported_at ASC, c.id ASC
+        LIMIT $1 OFFSET $2`, commentColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	comments := []*ReportedComment{}
+
+	for rows.Next() {
+		var comment ReportedComment
+
+		dest := scanComment(&comment.Comment, &totalRecords)
+		dest = append(dest, &comment.Reports, pq.Array(&comment.Reasons), &comment.FirstReportedAt)
+
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		comments = append(comments, &comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return comments, metadata, nil
+}
+
+// "Resolve()" zatvara sve otvorene prijave komentara (unutar jedne transakcije)
+// za "remove" razrješenje, komentar se briše u ime moderatora
+// "ErrRecordNotFound" se vraća ukoliko komentar nema otvorenih prijava
+func (m CommentModel) Resolve(commentID, moderatorID int64, resolution string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        UPDATE comment_reports
+        SET resolved_at = NOW(), resolved_by = $2, resolution = $3
+        WHERE comment_id = $1 AND resolved_at IS NULL`
+
+	result, err := tx.ExecContext(ctx, query, commentID, moderatorID, resolution)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	if resolution == "remove" {
+		query = `
+            UPDATE comments
+            SET deleted_at = NOW(), deleted_by = $2, version = version + 1
+            WHERE id = $1 AND deleted_at IS NULL`
+
+		_, err = tx.ExecContext(ctx, query, commentID, moderatorID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,177 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	validator "greenlight.lazarmrkic.com/internal"
+	"maps"
+	"regexp"
+	"time"
+)
+
+var ErrDuplicateExternalID = errors.New("duplicate external id")
+
+// podržani spoljni izvori i format identifikatora za svaki od njih
+var ExternalIDSources = map[string]*regexp.Regexp{
+	"imdb": regexp.MustCompile(`^tt[0-9]{7,10}$`),
+	"tmdb": regexp.MustCompile(`^[1-9][0-9]{0,9}$`),
+}
+
+func ValidateExternalIDs(v *validator.Validator, ids map[string]string) {
+	for source, id := range ids {
+		rx, ok := ExternalIDSources[source]
+		if !ok {
+			v.AddError("external_ids", "contains an unsupported source: "+source)
+			continue
+		}
+
+		v.Check(rx.MatchString(id), "external_ids", "contains an invalid "+source+" id: "+id)
+	}
+}
+
+// "GetByExternalID()" vraća film kom je dodijeljen dati identifikator iz spoljnog izvora
+func (m MovieModel) GetByExternalID(source, externalID string) (*Movie, error) {
+	query := `
+        SELECT movie_id
+        FROM movie_external_ids
+        WHERE source = $1 AND external_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+
+	err := m.DB.QueryRowContext(ctx, query, source, externalID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m.Get(id)
+}
+
+// učitava identifikatore jednog filma (prazna mapa ukoliko ih nema)
+func (m MovieModel) loadExternalIDs(movie *Movie) error {
+	ids, err := m.GetExternalIDs([]int64{movie.ID})
+	if err != nil {
+		return err
+	}
+
+	movie.ExternalIDs = ids[movie.ID]
+	if movie.ExternalIDs == nil {
+		movie.ExternalIDs = map[string]string{}
+	}
+
+	return nil
+}
+
+// "GetExternalIDs()" vraća spoljne identifikatore za svaki od zadatih filmova
+// filmovi bez identifikatora se ne nalaze u mapi
+func (m MovieModel) GetExternalIDs(movieIDs []int64) (map[int64]map[string]string, error) {
+	query := `
+        SELECT movie_id, source, external_id
+        FROM movie_external_ids
+        WHERE movie_id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]map[string]string)
+
+	for rows.Next() {
+		var movieID int64
+		var source, externalID string
+
+		err := rows.Scan(&movieID, &source, &externalID)
+		if err != nil {
+			return nil, err
+		}
+
+		if ids[movieID] == nil {
+			ids[movieID] = make(map[string]string)
+		}
+		ids[movieID][source] = externalID
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// "replaceExternalIDs()" zamjenjuje sve spoljne identifikatore filma unutar postojeće transakcije
+// ukoliko su identifikatori isti kao sačuvani (recimo, izmjena ih nije dotakla), redovi se ne diraju
+func replaceExternalIDs(ctx context.Context, tx *sql.Tx, movieID int64, ids map[string]string) error {
+	current, err := getExternalIDsTx(ctx, tx, movieID)
+	if err != nil {
+		return err
+	}
+
+	if maps.Equal(current, ids) {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_external_ids WHERE movie_id = $1`, movieID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO movie_external_ids (movie_id, source, external_id)
+        VALUES ($1, $2, $3)`
+
+	for source, externalID := range ids {
+		_, err = tx.ExecContext(ctx, query, movieID, source, externalID)
+		if err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "movie_external_ids_source_external_id_key"`:
+				return ErrDuplicateExternalID
+			default:
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// "getExternalIDsTx()" vraća spoljne identifikatore filma unutar postojeće transakcije (prazna mapa ukoliko ih nema)
+func getExternalIDsTx(ctx context.Context, tx *sql.Tx, movieID int64) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT source, external_id FROM movie_external_ids WHERE movie_id = $1`, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]string)
+
+	for rows.Next() {
+		var source, externalID string
+
+		err := rows.Scan(&source, &externalID)
+		if err != nil {
+			return nil, err
+		}
+
+		ids[source] = externalID
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
@@ -1,9 +1,13 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	validator "greenlight.lazarmrkic.com/internal"
 	"math"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // "page" / "page_size" i "sort" su parametri koje ćemo koristiti i na drugim "endpoint"-ovima
@@ -14,15 +18,78 @@ type Filters struct {
 	Sort     string
 	// dodajemo podržane vrijednosti za sortiranje ("id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime")
 	SortSafeList []string
+	// "keyset" (cursor) paginacija - uključuje se kada klijent pošalje "cursor" parametar (prazan za prvu stranicu)
+	// u tom slučaju se "page" ignoriše, a umjesto "OFFSET"-a se nastavlja od vrijednosti sačuvane u kursoru
+	UseCursor bool
+	Cursor    string
 }
 
 // ovaj "struct" će sadržati "pagination" metadada:
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+}
+
+// sadržaj kursora za "keyset" paginaciju
+// čuva se vrijednost kolone po kojoj se sortira i "id" (kao "tiebreaker") zapisa na granici stranice
+// klijent ga dobija kao "opaque" string (JSON enkodiran u "base64")
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+	// "Prev" označava da se traži stranica PRIJE zapisa iz kursora
+	Prev bool `json:"p,omitempty"`
+}
+
+// tipovi kolona po kojima je moguća "keyset" paginacija
+// vrijednost iz kursora se poredi direktno sa kolonom, pa mora da odgovara njenom tipu
+// (u suprotnom bi PostgreSQL vratio grešku prilikom konverzije, a klijent "500" umjesto "422")
+var cursorColumnTypes = map[string]string{
+	"id":      "bigint",
+	"title":   "text",
+	"year":    "integer",
+	"runtime": "integer",
+}
+
+// "validCursorValue()" provjerava da li se vrijednost iz kursora može konvertovati u tip kolone
+func validCursorValue(column, value string) bool {
+	var err error
+
+	switch cursorColumnTypes[column] {
+	case "bigint":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "integer":
+		_, err = strconv.ParseInt(value, 10, 32)
+	case "text":
+		// PostgreSQL ne prihvata neispravan UTF-8 i "NUL" karakter unutar "text" vrijednosti
+		return utf8.ValidString(value) && !strings.ContainsRune(value, 0)
+	default:
+		return false
+	}
+
+	return err == nil
+}
+
+func encodeCursor(c cursor) string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+
+	err = json.Unmarshal(js, &c)
+	return c, err
 }
 
 func ValidateFilters(v *validator.Validator, f Filters) {
@@ -34,6 +101,18 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 
 	// provjera da li se "sort" parametri nalaze u okviru "safe list" vrijednosti
 	v.Check(validator.PermittedValue(f.Sort, f.SortSafeList...), "sort", "invalid sort value")
+
+	// kursor mora da bude ispravan i da odgovara trenutnom sortiranju:
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil, "cursor", "invalid cursor")
+		v.Check(err != nil || c.Sort == f.Sort, "cursor", "does not match the sort parameter")
+
+		// vrijednost se provjerava samo za ispravan kursor i dozvoljeno sortiranje ("sortColumn()" inače izaziva "panic")
+		if err == nil && c.Sort == f.Sort && validator.PermittedValue(f.Sort, f.SortSafeList...) {
+			v.Check(validCursorValue(f.sortColumn(), c.Value), "cursor", "invalid cursor")
+		}
+	}
 }
 
 // "sortColumn()" i "sortDirection()" helper metode transformišu "query string" vrijednost (recimo "-year") u vrijednosti koje možemo da koristimo unutar SQL "query"-ja
@@ -51,8 +130,9 @@ func (f Filters) sortColumn() string {
 }
 
 // vraća se smjer sortiranja ("ASC" ili "DESC"), u zavisnosti od "prefix" karaktera unutar "Sort" polja
+// "relevance" i "popularity" se uvijek sortiraju od najveće ka najmanjoj vrijednosti
 func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
+	if strings.HasPrefix(f.Sort, "-") || f.Sort == "relevance" || f.Sort == "popularity" {
 		return "DESC"
 	}
 
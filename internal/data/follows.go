@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// greška kada korisnik pokuša da prati samog sebe
+var ErrSelfFollow = errors.New("cannot follow yourself")
+
+// korisnik iz liste praćenih korisnika ili pratilaca
+type FollowedUser struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// "FollowModel" služi za interakciju sa "follows" tabelom
+type FollowModel struct {
+	DB *sql.DB
+}
+
+// "Follow()" - korisnik "followerID" počinje da prati korisnika "followeeID"
+// praćenje je idempotentno, a "ErrRecordNotFound" se vraća ukoliko korisnik ne postoji ili nije aktiviran
+func (m FollowModel) Follow(followerID, followeeID int64) error {
+	if followerID == followeeID {
+		return ErrSelfFollow
+	}
+
+	query := `
+        WITH followee AS (
+            SELECT id FROM users WHERE id = $2 AND activated
+        ),
+        inserted AS (
+            INSERT INTO follows (follower_id, followee_id)
+            SELECT $1, id FROM followee
+            ON CONFLICT DO NOTHING
+        )
+        SELECT EXISTS (SELECT 1 FROM followee)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+
+	err := m.DB.QueryRowContext(ctx, query, followerID, followeeID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m FollowModel) Unfollow(followerID, followeeID int64) error {
+	query := `
+        DELETE FROM follows
+        WHERE follower_id = $1 AND followee_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// "GetFollowing()" vraća korisnike koje prati "userID", a "GetFollowers()" korisnike koji prate "userID"
+// obje liste su sortirane od najnovijeg praćenja, uz paginaciju
+func (m FollowModel) GetFollowing(userID int64, filters Filters) ([]*FollowedUser, Metadata, error) {
+	return m.getUsers("followee_id", "follower_id", userID, filters)
+}
+
+func (m FollowModel) GetFollowers(userID int64, filters Filters) ([]*FollowedUser, Metadata, error) {
+	return m.getUsers("follower_id", "followee_id", userID, filters)
+}
+
+func (m FollowModel) getUsers(userColumn, filterColumn string, userID int64, filters Filters) ([]*FollowedUser, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), u.id, u.name, f.created_at
+        FROM follows f
+        INNER JOIN users u ON u.id = f.%s
+        WHERE f.%s = $1
+        ORDER BY f.created_at DESC, u.id ASC
+        LIMIT $2 OFFSET $3`, userColumn, filterColumn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	users := []*FollowedUser{}
+
+	for rows.Next() {
+		var user FollowedUser
+
+		err := rows.Scan(&totalRecords, &user.ID, &user.Name, &user.FollowedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return users, metadata, nil
+}
@@ -0,0 +1,366 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	validator "greenlight.lazarmrkic.com/internal"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	// žanr koji koristi bar jedan film ne može da se obriše
+	ErrGenreInUse = errors.New("genre in use")
+)
+
+// žanr iz referentne tabele
+// filmovi čuvaju "slug" žanra, a "aliases" sadrži alternativne nazive koji se prilikom validacije zamjenjuju "slug"-om
+type Genre struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	Aliases   []string  `json:"aliases"`
+	Version   int32     `json:"version"`
+}
+
+var genreSlugRX = regexp.MustCompile("[^a-z0-9]+")
+
+// "NormalizeGenre()" pretvara proizvoljan naziv žanra u oblik koji se poredi sa "slug"-ovima i alias-ima
+// ("Sci Fi", "sci-fi" i "SCI_FI" daju isti rezultat)
+// ista pravila koristi i migracija koja je mapirala postojeće vrijednosti
+func NormalizeGenre(value string) string {
+	slug := strings.Trim(genreSlugRX.ReplaceAllString(strings.ToLower(value), "-"), "-")
+	if slug == "" {
+		return strings.ToLower(strings.TrimSpace(value))
+	}
+
+	return slug
+}
+
+// "GenreTaxonomy" mapira normalizovane "slug"-ove i alias-e na žanr kom pripadaju
+type GenreTaxonomy map[string]*Genre
+
+func NewGenreTaxonomy(genres []*Genre) GenreTaxonomy {
+	taxonomy := make(GenreTaxonomy)
+
+	for _, genre := range genres {
+		for _, alias := range genre.Aliases {
+			taxonomy[NormalizeGenre(alias)] = genre
+		}
+		taxonomy[genre.Slug] = genre
+	}
+
+	return taxonomy
+}
+
+// "Canonical()" vraća "slug" žanra kom pripada dati naziv ili alias
+func (t GenreTaxonomy) Canonical(value string) (string, bool) {
+	genre, ok := t[NormalizeGenre(value)]
+	if !ok {
+		return "", false
+	}
+
+	return genre.Slug, true
+}
+
+// "GenreModel" služi za interakciju sa "genres" tabelom
+type GenreModel struct {
+	DB *sql.DB
+}
+
+func (m GenreModel) Insert(genre *Genre) error {
+	query := `
+        INSERT INTO genres (slug, name, aliases)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at, version`
+
+	args := []any{genre.Slug, genre.Name, pq.Array(genre.Aliases)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&genre.ID, &genre.CreatedAt, &genre.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "genres_slug_key"`:
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m GenreModel) Get(id int64) (*Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+        SELECT id, created_at, slug, name, aliases, version
+        FROM genres
+        WHERE id = $1`
+
+	var genre Genre
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&genre.ID,
+		&genre.CreatedAt,
+		&genre.Slug,
+		&genre.Name,
+		pq.Array(&genre.Aliases),
+		&genre.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &genre, nil
+}
+
+// "GetAll()" vraća sve žanrove sortirane po "slug"-u
+// tabela je mala (nekoliko desetina redova), pa nema potrebe za paginacijom
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+        SELECT id, created_at, slug, name, aliases, version
+        FROM genres
+        ORDER BY slug`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*Genre{}
+
+	for rows.Next() {
+		var genre Genre
+
+		err := rows.Scan(
+			&genre.ID,
+			&genre.CreatedAt,
+			&genre.Slug,
+			&genre.Name,
+			pq.Array(&genre.Aliases),
+			&genre.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
+
+// "Update()" koristi "optimistic locking" preko "version" kolone, kao i "MovieModel.Update()"
+// ukoliko se "slug" promijeni, stari "slug" se zamjenjuje novim u svim filmovima unutar iste transakcije
+// stari "slug" se tada dodaje i u alias-e, pa klijenti i "import" koji ga još šalju i dalje prolaze validaciju
+// za svaki izmijenjeni film se upisuju "movie.updated" poruka u "outbox" i događaj za "stream", kao i kod "MovieModel.Update()"
+func (m GenreModel) Update(genre *Genre, previousSlug string) error {
+	query := `
+        UPDATE genres
+        SET slug = $1, name = $2, version = version + 1,
+            aliases = CASE WHEN $6 <> $1 AND NOT $6 = ANY($3::text[]) THEN array_append($3::text[], $6) ELSE $3::text[] END
+        WHERE id = $4 AND version = $5
+        RETURNING version, aliases`
+
+	args := []any{genre.Slug, genre.Name, pq.Array(genre.Aliases), genre.ID, genre.Version, previousSlug}
+
+	// promjena "slug"-a može da izmijeni veći broj filmova, pa transakcija ima više vremena od pojedinačnih upita
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&genre.Version, pq.Array(&genre.Aliases))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err.Error() == `pq: duplicate key value violates unique constraint "genres_slug_key"`:
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+
+	if previousSlug == genre.Slug {
+		return tx.Commit()
+	}
+
+	// izmjene koje čekaju odobrenje se ne objavljuju, pa se samo ažuriraju (bez poruka i događaja)
+	query = `
+        UPDATE movie_pending_edits
+        SET genres = array_replace(genres, $1, $2)
+        WHERE $1 = ANY(genres)`
+
+	_, err = tx.ExecContext(ctx, query, previousSlug, genre.Slug)
+	if err != nil {
+		return err
+	}
+
+	query = `
+        UPDATE movies
+        SET genres = array_replace(genres, $1, $2), version = version + 1
+        WHERE $1 = ANY(genres)
+        RETURNING id`
+
+	rows, err := tx.QueryContext(ctx, query, previousSlug, genre.Slug)
+	if err != nil {
+		return err
+	}
+
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+
+		err := rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	movies := make([]*Movie, len(ids))
+
+	for i, id := range ids {
+		movies[i], err = getMovieTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		err = insertOutbox(ctx, tx, OutboxMovieUpdated, movies[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	// događaji za "stream" se upisuju na kraju transakcije, jednim upitom (pogledati "insertMovieEvents()")
+	events := make([]movieEventInput, len(movies))
+	for i, movie := range movies {
+		events[i] = movieEventInput{Type: "movie.updated", MovieID: movie.ID, Data: movie}
+	}
+
+	err = insertMovieEvents(ctx, tx, events)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// "Delete()" briše žanr samo ukoliko ga nijedan film ne koristi
+func (m GenreModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// "FOR UPDATE" zaključava red, pa "Update()" ne može istovremeno da promijeni "slug"
+	// žanr koji koristi izmjena koja čeka odobrenje se takođe smatra korišćenim
+	var inUse bool
+
+	query := `
+        SELECT EXISTS (SELECT 1 FROM movies WHERE genres.slug = ANY(movies.genres))
+            OR EXISTS (SELECT 1 FROM movie_pending_edits WHERE genres.slug = ANY(movie_pending_edits.genres))
+        FROM genres
+        WHERE id = $1
+        FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, query, id).Scan(&inUse)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if inUse {
+		return ErrGenreInUse
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM genres WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// "ValidateGenre()" provjerava da se "slug" i alias-i ne preklapaju sa drugim žanrovima iz "taxonomy" mape
+// alias-i se normalizuju, kako bi se poklapali sa vrijednostima koje šalju klijenti
+func ValidateGenre(v *validator.Validator, genre *Genre, taxonomy GenreTaxonomy) {
+	v.Check(genre.Slug != "", "slug", "must be provided")
+	v.Check(genre.Slug == NormalizeGenre(genre.Slug), "slug", "must contain only lowercase letters, digits and hyphens")
+	v.Check(len(genre.Slug) <= 100, "slug", "must not be more than 100 bytes long")
+
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(len(genre.Aliases) <= 20, "aliases", "must not contain more than 20 aliases")
+
+	for i, alias := range genre.Aliases {
+		genre.Aliases[i] = NormalizeGenre(alias)
+	}
+
+	v.Check(validator.Unique(genre.Aliases), "aliases", "must not contain duplicate values")
+	v.Check(!validator.PermittedValue(genre.Slug, genre.Aliases...), "aliases", "must not contain the slug")
+
+	if other, ok := taxonomy[genre.Slug]; ok && other.ID != genre.ID {
+		v.AddError("slug", "is already used by the genre "+other.Slug)
+	}
+
+	for _, alias := range genre.Aliases {
+		if other, ok := taxonomy[alias]; ok && other.ID != genre.ID {
+			v.AddError("aliases", "contains a value already used by the genre "+other.Slug)
+			break
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	validator "greenlight.lazarmrkic.com/internal"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// BCP 47 oznaka jezika sa opcionim regionom ("sr", "sr-Latn", "en-US",...)
+	LocaleRX = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+	// ISO 3166-1 alpha-2 kod zemlje
+	CountryRX = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// alternativni naslov filma za određeni jezik/region
+type MovieTitle struct {
+	Locale string `json:"locale"`
+	Title  string `json:"title"`
+}
+
+// datum izlaska i starosna granica filma u jednoj zemlji
+// datum se čuva u "YYYY-MM-DD" formatu, jer nema vremensku zonu
+type MovieRelease struct {
+	Country       string `json:"country"`
+	ReleaseDate   string `json:"release_date"`
+	Certification string `json:"certification"`
+}
+
+// "LocalizationModel" služi za interakciju sa "movie_titles" i "movie_releases" tabelama
+type LocalizationModel struct {
+	DB *sql.DB
+}
+
+// "GetTitles()" vraća alternativne naslove za svaki od zadatih filmova
+func (m LocalizationModel) GetTitles(movieIDs []int64) (map[int64][]MovieTitle, error) {
+	query := `
+        SELECT movie_id, locale, title
+        FROM movie_titles
+        WHERE movie_id = ANY($1)
+        ORDER BY movie_id, locale`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := make(map[int64][]MovieTitle)
+
+	for rows.Next() {
+		var movieID int64
+		var title MovieTitle
+
+		err := rows.Scan(&movieID, &title.Locale, &title.Title)
+		if err != nil {
+			return nil, err
+		}
+
+		titles[movieID] = append(titles[movieID], title)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return titles, nil
+}
+
+// "GetReleases()" vraća datume izlaska za svaki od zadatih filmova, sortirane po datumu
+func (m LocalizationModel) GetReleases(movieIDs []int64) (map[int64][]MovieRelease, error) {
+	query := `
+        SELECT movie_id, country, to_char(release_date, 'YYYY-MM-DD'), certification
+        FROM movie_releases
+        WHERE movie_id = ANY($1)
+        ORDER BY movie_id, release_date, country`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := make(map[int64][]MovieRelease)
+
+	for rows.Next() {
+		var movieID int64
+		var release MovieRelease
+
+		err := rows.Scan(&movieID, &release.Country, &release.ReleaseDate, &release.Certification)
+		if err != nil {
+			return nil, err
+		}
+
+		releases[movieID] = append(releases[movieID], release)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+// "Replace()" zamjenjuje sve alternativne naslove i datume izlaska filma unutar jedne transakcije
+// verzija filma se povećava, jer lokalizovani naslov utiče na reprezentaciju filma (i na "ETag")
+// kao i kod "MovieModel.Update()", "ErrEditConflict" znači da je film u međuvremenu izmijenjen,
+// a lokalizacije objavljenog ili zakazanog filma se čuvaju kao izmjena koja čeka odobrenje (pogledati "MovieEdit")
+func (m LocalizationModel) Replace(movie *Movie, userID int64, titles []MovieTitle, releases []MovieRelease) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        UPDATE movies
+        SET version = version + 1
+        WHERE id = $1 AND version = $2
+        RETURNING version`
+
+	err = tx.QueryRowContext(ctx, query, movie.ID, movie.Version).Scan(&movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	if editRequiresReview(movie.Status) {
+		err = savePendingLocalizations(ctx, tx, movie, userID, titles, releases)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	err = replaceLocalizations(ctx, tx, movie.ID, titles, releases)
+	if err != nil {
+		return err
+	}
+
+	err = insertOutbox(ctx, tx, OutboxMovieUpdated, movie)
+	if err != nil {
+		return err
+	}
+
+	err = insertMovieEvent(ctx, tx, "movie.updated", movie.ID, movie)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// "replaceLocalizations()" zamjenjuje alternativne naslove i datume izlaska filma unutar postojeće transakcije
+func replaceLocalizations(ctx context.Context, tx *sql.Tx, movieID int64, titles []MovieTitle, releases []MovieRelease) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM movie_titles WHERE movie_id = $1`, movieID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_releases WHERE movie_id = $1`, movieID)
+	if err != nil {
+		return err
+	}
+
+	for _, title := range titles {
+		query := `
+            INSERT INTO movie_titles (movie_id, locale, title)
+            VALUES ($1, $2, $3)`
+
+		_, err = tx.ExecContext(ctx, query, movieID, title.Locale, title.Title)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, release := range releases {
+		query := `
+            INSERT INTO movie_releases (movie_id, country, release_date, certification)
+            VALUES ($1, $2, $3, $4)`
+
+		_, err = tx.ExecContext(ctx, query, movieID, release.Country, release.ReleaseDate, release.Certification)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// "locale" oznake se normalizuju na mala slova ("sr-RS" → "sr-rs"), a kodovi zemalja na velika
+func ValidateLocalizations(v *validator.Validator, titles []MovieTitle, releases []MovieRelease) {
+	v.Check(len(titles) <= 100, "titles", "must not contain more than 100 titles")
+	v.Check(len(releases) <= 250, "releases", "must not contain more than 250 releases")
+
+	locales := make([]string, len(titles))
+	for i := range titles {
+		titles[i].Locale = strings.ToLower(titles[i].Locale)
+		locales[i] = titles[i].Locale
+
+		v.Check(LocaleRX.MatchString(titles[i].Locale), "titles", "must contain valid locales (for example \"sr\" or \"en-US\")")
+		v.Check(titles[i].Title != "", "titles", "must not contain empty titles")
+		v.Check(len(titles[i].Title) <= 500, "titles", "must not contain titles longer than 500 bytes")
+	}
+	v.Check(validator.Unique(locales), "titles", "must not contain duplicate locales")
+
+	countries := make([]string, len(releases))
+	for i := range releases {
+		releases[i].Country = strings.ToUpper(releases[i].Country)
+		countries[i] = releases[i].Country
+
+		v.Check(CountryRX.MatchString(releases[i].Country), "releases", "must contain valid ISO 3166-1 alpha-2 country codes")
+
+		_, err := time.Parse(time.DateOnly, releases[i].ReleaseDate)
+		v.Check(err == nil, "releases", "must contain release dates in the YYYY-MM-DD format")
+
+		v.Check(len(releases[i].Certification) <= 20, "releases", "must not contain certifications longer than 20 bytes")
+	}
+	v.Check(validator.Unique(countries), "releases", "must not contain duplicate countries")
+}
+
+// "MatchLocale()" bira naslov koji najbolje odgovara jezicima koje klijent preferira (sortiranim po prioritetu)
+// za svaki jezik se prvo traži tačno poklapanje ("sr-rs"), zatim osnovni jezik ("sr"), a na kraju bilo koji region tog jezika ("sr-ba")
+func MatchLocale(preferred []string, titles []MovieTitle) (MovieTitle, bool) {
+	for _, locale := range preferred {
+		locale = strings.ToLower(locale)
+		language, _, _ := strings.Cut(locale, "-")
+
+		for _, candidate := range []string{locale, language} {
+			for _, title := range titles {
+				if title.Locale == candidate {
+					return title, true
+				}
+			}
+		}
+
+		for _, title := range titles {
+			if strings.HasPrefix(title.Locale, language+"-") {
+				return title, true
+			}
+		}
+	}
+
+	return MovieTitle{}, false
+}
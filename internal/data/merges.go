@@ -0,0 +1,316 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	validator "greenlight.lazarmrkic.com/internal"
+	"strconv"
+	"time"
+)
+
+// zapis o spajanju duplikata ("source") u kanonski film ("target")
+type MovieMerge struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	SourceID    int64     `json:"source_id"`
+	SourceTitle string    `json:"source_title"`
+	TargetID    int64     `json:"target_id"`
+	MergedBy    int64     `json:"merged_by"`
+}
+
+// kratka reprezentacija filma unutar izvještaja o duplikatima
+type DuplicateMovie struct {
+	ID      int64   `json:"id"`
+	Title   string  `json:"title"`
+	Year    int32   `json:"year"`
+	Runtime Runtime `json:"runtime"`
+}
+
+// par filmova koji su vjerovatno duplikati
+// "Similarity" je "pg_trgm" sličnost naslova (od 0 do 1)
+type DuplicateCandidate struct {
+	Movie      DuplicateMovie `json:"movie"`
+	Duplicate  DuplicateMovie `json:"duplicate"`
+	Similarity float64        `json:"similarity"`
+}
+
+// kriterijumi za izvještaj o duplikatima
+type DuplicateCriteria struct {
+	// minimalna sličnost naslova (0-1)
+	Threshold float64
+	// dozvoljena razlika u godini i trajanju (u minutima)
+	YearTolerance    int
+	RuntimeTolerance int
+}
+
+func ValidateDuplicateCriteria(v *validator.Validator, c DuplicateCriteria) {
+	v.Check(c.Threshold > 0 && c.Threshold <= 1, "threshold", "must be greater than 0 and at most 1")
+	v.Check(c.YearTolerance >= 0 && c.YearTolerance <= 5, "year_tolerance", "must be between 0 and 5")
+	v.Check(c.RuntimeTolerance >= 0 && c.RuntimeTolerance <= 60, "runtime_tolerance", "must be between 0 and 60")
+}
+
+// "GetDuplicateCandidates()" vraća parove filmova sa sličnim naslovom, godinom i trajanjem
+// svaki par se vraća samo jednom ("a.id < b.id"), a parovi su sortirani od najsličnijih
+//
+// spajanje se vrši preko "%" operatora, kako bi se za svaki film parovi tražili preko "movies_title_trgm_idx" indeksa
+// (uslov "similarity() >= $1" ne može da koristi indeks, pa bi se svaki film poredio sa svim ostalima)
+// "%" poredi sa "pg_trgm.similarity_threshold" podešavanjem, koje se postavlja samo za ovu transakciju
+// "pg_trgm" ne razlikuje velika i mala slova, pa naslovi ne moraju da se prebacuju u "lower()"
+func (m MovieModel) GetDuplicateCandidates(criteria DuplicateCriteria, filters Filters) ([]*DuplicateCandidate, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), a.id, a.title, a.year, a.runtime, b.id, b.title, b.year, b.runtime, similarity(a.title, b.title) AS score
+        FROM movies a
+        INNER JOIN movies b ON b.title % a.title
+            AND a.id < b.id
+            AND b.year BETWEEN a.year - $1 AND a.year + $1
+            AND b.runtime BETWEEN a.runtime - $2 AND a.runtime + $2
+        ORDER BY score DESC, a.id ASC, b.id ASC
+        LIMIT $3 OFFSET $4`
+
+	args := []any{criteria.YearTolerance, criteria.RuntimeTolerance, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer tx.Rollback()
+
+	// treći parametar ("true") ograničava podešavanje na trenutnu transakciju, pa ono ne ostaje na konekciji iz "pool"-a
+	_, err = tx.ExecContext(ctx, `SELECT set_config('pg_trgm.similarity_threshold', $1, true)`, strconv.FormatFloat(criteria.Threshold, 'f', -1, 64))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	candidates := []*DuplicateCandidate{}
+
+	for rows.Next() {
+		var c DuplicateCandidate
+
+		err := rows.Scan(
+			&totalRecords,
+			&c.Movie.ID,
+			&c.Movie.Title,
+			&c.Movie.Year,
+			&c.Movie.Runtime,
+			&c.Duplicate.ID,
+			&c.Duplicate.Title,
+			&c.Duplicate.Year,
+			&c.Duplicate.Runtime,
+			&c.Similarity,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		candidates = append(candidates, &c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return candidates, metadata, nil
+}
+
+// "MergeModel" služi za spajanje duplikata i interakciju sa "movie_merges" tabelom
+type MergeModel struct {
+	DB *sql.DB
+}
+
+// "Merge()" spaja film "sourceID" u film "targetID" unutar jedne transakcije:
+//   - zavisni redovi (ocjene, odgledani filmovi, pregledi, komentari, istorija recenzija, alternativni naslovi, datumi izlaska, spoljni identifikatori) se prebacuju na "target",
+//     osim ukoliko "target" već ima odgovarajući red (recimo, korisnik je ocijenio oba filma) - tada se zadržava vrijednost "target"-a
+//     (izuzetak je broj pregleda po satu, koji se sabira)
+//   - "source" film se briše, a ostatak zavisnih redova se briše kaskadno (sličnosti filmova se ponovo računaju pri narednom osvježavanju preporuka)
+//   - preusmjerenja koja su pokazivala na "source" se preusmjeravaju na "target" (lanac spajanja se ne formira)
+//   - "target" preuzima "external_key" od "source"-a ukoliko ga nema, a verzija mu se povećava
+//
+// nove tabele koje referenciraju filmove treba dodati ovdje
+// "targetVersion" različit od nule predstavlja verziju koju klijent očekuje ("ErrEditConflict" ukoliko se ne poklapa)
+func (m MergeModel) Merge(sourceID, targetID int64, targetVersion int32, userID int64) (*MovieMerge, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// oba reda se zaključavaju uvijek istim redoslijedom (po "id"), kako bi se izbjegao "deadlock"
+	query := `
+        SELECT id, title, COALESCE(external_key, ''), version
+        FROM movies
+        WHERE id = ANY($1)
+        ORDER BY id
+        FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array([]int64{sourceID, targetID}))
+	if err != nil {
+		return nil, err
+	}
+
+	var source, target *Movie
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(&movie.ID, &movie.Title, &movie.ExternalKey, &movie.Version)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		switch movie.ID {
+		case sourceID:
+			source = &movie
+		case targetID:
+			target = &movie
+		}
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if source == nil || target == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	if targetVersion != 0 && target.Version != targetVersion {
+		return nil, ErrEditConflict
+	}
+
+	statements := []string{
+		`UPDATE ratings SET movie_id = $2
+        WHERE movie_id = $1 AND user_id NOT IN (SELECT user_id FROM ratings WHERE movie_id = $2)`,
+		`UPDATE movie_titles SET movie_id = $2
+        WHERE movie_id = $1 AND locale NOT IN (SELECT locale FROM movie_titles WHERE movie_id = $2)`,
+		`UPDATE movie_releases SET movie_id = $2
+        WHERE movie_id = $1 AND country NOT IN (SELECT country FROM movie_releases WHERE movie_id = $2)`,
+		`UPDATE movie_reviews SET movie_id = $2 WHERE movie_id = $1`,
+		`UPDATE movie_external_ids SET movie_id = $2
+        WHERE movie_id = $1 AND source NOT IN (SELECT source FROM movie_external_ids WHERE movie_id = $2)`,
+		`UPDATE watched_movies SET movie_id = $2
+        WHERE movie_id = $1 AND user_id NOT IN (SELECT user_id FROM watched_movies WHERE movie_id = $2)`,
+		`UPDATE movie_views SET movie_id = $2 WHERE movie_id = $1`,
+		`INSERT INTO movie_view_stats (movie_id, bucket, views)
+        SELECT $2, bucket, views FROM movie_view_stats WHERE movie_id = $1
+        ON CONFLICT (movie_id, bucket) DO UPDATE SET views = movie_view_stats.views + EXCLUDED.views`,
+		`UPDATE comments SET movie_id = $2 WHERE movie_id = $1`,
+		`UPDATE activities SET movie_id = $2 WHERE movie_id = $1`,
+		`UPDATE movie_merges SET target_id = $2 WHERE target_id = $1`,
+	}
+
+	for _, statement := range statements {
+		_, err = tx.ExecContext(ctx, statement, sourceID, targetID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movies WHERE id = $1`, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+        UPDATE movies
+        SET external_key = COALESCE(external_key, NULLIF($2, '')), version = version + 1
+        WHERE id = $1`
+
+	_, err = tx.ExecContext(ctx, query, targetID, source.ExternalKey)
+	if err != nil {
+		return nil, err
+	}
+
+	merge := &MovieMerge{
+		SourceID:    sourceID,
+		SourceTitle: source.Title,
+		TargetID:    targetID,
+		MergedBy:    userID,
+	}
+
+	query = `
+        INSERT INTO movie_merges (source_id, source_title, target_id, merged_by)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, sourceID, source.Title, targetID, userID).Scan(&merge.ID, &merge.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// za spoljne sisteme (i "stream"), duplikat je obrisan, a kanonski film izmijenjen
+	deleted := map[string]int64{"id": sourceID, "merged_into": targetID}
+
+	err = insertOutbox(ctx, tx, OutboxMovieDeleted, deleted)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := getMovieTx(ctx, tx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertOutbox(ctx, tx, OutboxMovieUpdated, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertMovieEvents(ctx, tx, []movieEventInput{
+		{Type: "movie.deleted", MovieID: sourceID, Data: deleted},
+		{Type: "movie.updated", MovieID: targetID, Data: updated},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+// "GetRedirect()" vraća ID filma u koji je spojen film sa datim ID-em
+func (m MergeModel) GetRedirect(sourceID int64) (int64, error) {
+	query := `
+        SELECT target_id
+        FROM movie_merges
+        WHERE source_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var targetID int64
+
+	err := m.DB.QueryRowContext(ctx, query, sourceID).Scan(&targetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return targetID, nil
+}
@@ -15,18 +15,44 @@ var (
 // unutar ovog "struct"-a ćemo čuvati sve modele
 // imaće funkciju "container"-a i biće pogodan za našu svrhu, jer će biti dosta modela kako aplikacija bude rasla
 type Models struct {
-	Users       UserModel
-	Permissions PermissionModel
-	Movies      MovieModel
-	Tokens      TokenModel
+	Users           UserModel
+	Permissions     PermissionModel
+	Movies          MovieModel
+	MovieEvents     MovieEventModel
+	Genres          GenreModel
+	Localizations   LocalizationModel
+	Merges          MergeModel
+	Reviews         ReviewModel
+	Ratings         RatingModel
+	Recommendations RecommendationModel
+	Views           ViewModel
+	Comments        CommentModel
+	Follows         FollowModel
+	Activities      ActivityModel
+	Webhooks        WebhookModel
+	Outbox          OutboxModel
+	Tokens          TokenModel
 }
 
 // ova metoda vraća "Models" struct koji sadrži INICIJALIZOVAN "MovieModel"
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Users:       UserModel{DB: db},
-		Permissions: PermissionModel{DB: db},
-		Movies:      MovieModel{DB: db},
-		Tokens:      TokenModel{DB: db},
+		Users:           UserModel{DB: db},
+		Permissions:     PermissionModel{DB: db},
+		Movies:          MovieModel{DB: db},
+		MovieEvents:     MovieEventModel{DB: db},
+		Genres:          GenreModel{DB: db},
+		Localizations:   LocalizationModel{DB: db},
+		Merges:          MergeModel{DB: db},
+		Reviews:         ReviewModel{DB: db},
+		Ratings:         RatingModel{DB: db},
+		Recommendations: RecommendationModel{DB: db},
+		Views:           ViewModel{DB: db},
+		Comments:        CommentModel{DB: db},
+		Follows:         FollowModel{DB: db},
+		Activities:      ActivityModel{DB: db},
+		Webhooks:        WebhookModel{DB: db},
+		Outbox:          OutboxModel{DB: db},
+		Tokens:          TokenModel{DB: db},
 	}
 }
@@ -0,0 +1,211 @@
+package data
+
+import (
+	"github.com/lib/pq"
+	validator "greenlight.lazarmrkic.com/internal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kriterijumi za filtriranje filmova - koriste ih "GetAll()" i "Export()"
+// sva polja su opciona, a "zero" vrijednost znači da se filter ne primjenjuje
+type MovieCriteria struct {
+	// pretraga po naslovu - "Fuzzy" uključuje i "pg_trgm" sličnost (tolerantna na greške u kucanju)
+	Title string
+	Fuzzy bool
+	// film mora da sadrži SVE navedene žanrove
+	Genres []string
+	// film mora da sadrži BAR JEDAN od navedenih žanrova
+	GenresAny []string
+	// film ne smije da sadrži nijedan od navedenih žanrova
+	GenresExclude []string
+
+	YearMin    int
+	YearMax    int
+	RuntimeMin int
+	RuntimeMax int
+
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// film mora da bude u jednoj od navedenih faza ("published", "draft",...)
+	Statuses []string
+	// samo filmovi koji su trenutno vidljivi korisnicima koji samo čitaju katalog
+	PublishedOnly bool
+}
+
+// "ValidateMovieCriteria()" pored provjere, zamjenjuje nazive i alias-e žanrova "slug"-ovima (kao i "ValidateMovie()")
+// filmovi čuvaju samo "slug"-ove, pa bi "?genres=Sci-Fi" bez toga vratio prazan rezultat
+func ValidateMovieCriteria(v *validator.Validator, c *MovieCriteria, genres GenreTaxonomy) {
+	maxYear := time.Now().Year()
+
+	v.Check(c.YearMin == 0 || (c.YearMin >= 1888 && c.YearMin <= maxYear), "year_min", "must be between 1888 and the current year")
+	v.Check(c.YearMax == 0 || (c.YearMax >= 1888 && c.YearMax <= maxYear), "year_max", "must be between 1888 and the current year")
+	v.Check(c.YearMin == 0 || c.YearMax == 0 || c.YearMin <= c.YearMax, "year_max", "must be greater than or equal to year_min")
+
+	v.Check(c.RuntimeMin >= 0, "runtime_min", "must not be negative")
+	v.Check(c.RuntimeMax >= 0, "runtime_max", "must not be negative")
+	v.Check(c.RuntimeMin == 0 || c.RuntimeMax == 0 || c.RuntimeMin <= c.RuntimeMax, "runtime_max", "must be greater than or equal to runtime_min")
+
+	v.Check(c.CreatedAfter.IsZero() || c.CreatedBefore.IsZero() || c.CreatedAfter.Before(c.CreatedBefore), "created_before", "must be later than created_after")
+
+	for _, status := range c.Statuses {
+		v.Check(validator.PermittedValue(status, MovieStatuses...), "status", "must only contain draft, in_review, scheduled, published or archived")
+	}
+
+	for key, values := range map[string][]string{"genres": c.Genres, "genres_any": c.GenresAny, "genres_exclude": c.GenresExclude} {
+		v.Check(len(values) <= 20, key, "must not contain more than 20 genres")
+
+		// nepoznat žanr se odbija, isto kao i prilikom upisa filma
+		for i, genre := range values {
+			slug, ok := genres.Canonical(genre)
+			if !ok {
+				v.AddError(key, "contains an unknown genre: "+genre)
+				continue
+			}
+			values[i] = slug
+		}
+	}
+}
+
+// "where()" sastavlja "WHERE" uslov i pripadajuće "placeholder" parametre
+// u SQL se dodaju samo unaprijed definisani fragmenti, a sve vrijednosti koje šalje klijent idu kroz "placeholder" parametre
+// naslov je uvijek prvi parametar ("$1"), jer ga koriste i "movieSearchColumns" kolone
+func (c MovieCriteria) where() (string, []any) {
+	args := []any{c.Title}
+
+	// dodaje vrijednost u "args" i vraća odgovarajući "placeholder" (recimo "$3")
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	conditions := []string{movieTitleCondition(c.Fuzzy)}
+
+	// "@>" - sadrži sve, "&&" - preklapanje (bar jedan zajednički element)
+	if len(c.Genres) > 0 {
+		conditions = append(conditions, "genres @> "+arg(pq.Array(c.Genres)))
+	}
+	if len(c.GenresAny) > 0 {
+		conditions = append(conditions, "genres && "+arg(pq.Array(c.GenresAny)))
+	}
+	if len(c.GenresExclude) > 0 {
+		conditions = append(conditions, "NOT genres && "+arg(pq.Array(c.GenresExclude)))
+	}
+
+	if c.YearMin != 0 {
+		conditions = append(conditions, "year >= "+arg(c.YearMin))
+	}
+	if c.YearMax != 0 {
+		conditions = append(conditions, "year <= "+arg(c.YearMax))
+	}
+	if c.RuntimeMin != 0 {
+		conditions = append(conditions, "runtime >= "+arg(c.RuntimeMin))
+	}
+	if c.RuntimeMax != 0 {
+		conditions = append(conditions, "runtime <= "+arg(c.RuntimeMax))
+	}
+
+	if !c.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at > "+arg(c.CreatedAfter))
+	}
+	if !c.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(c.CreatedBefore))
+	}
+
+	if len(c.Statuses) > 0 {
+		conditions = append(conditions, "status = ANY("+arg(pq.Array(c.Statuses))+")")
+	}
+	if c.PublishedOnly {
+		conditions = append(conditions, moviePublishedCondition)
+	}
+
+	return strings.Join(conditions, "\n        AND "), args
+}
+
+// film je vidljiv korisnicima koji samo čitaju katalog ukoliko je objavljen i nalazi se unutar "publish_at"/"unpublish_at" okvira
+// okvir se provjerava i u upitu (a ne samo u "scheduler"-u), pa embargo važi tačno u zadatom trenutku
+const moviePublishedCondition = `(status = 'published' AND (publish_at IS NULL OR publish_at <= now()) AND (unpublish_at IS NULL OR unpublish_at > now()))`
+
+// dodatne kolone za pretragu po naslovu ("$1"), koriste se za "relevance" sortiranje i "Highlight" polje
+// "ts_rank" ocjenjuje poklapanje cijelih riječi, a "word_similarity" (iz "pg_trgm" ekstenzije) poklapanje sa greškama u kucanju
+// "ts_headline" označava pronađene riječi unutar naslova - ukoliko nijedna riječ nije pronađena, vraća se prazan string
+const movieSearchColumns = `
+        CASE WHEN $1 = '' THEN 0
+            ELSE ts_rank(to_tsvector('simple', title), plainto_tsquery('simple', $1)) + word_similarity($1, title)
+        END AS relevance,
+        CASE WHEN $1 = '' OR NOT to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) THEN ''
+            ELSE ts_headline('simple', title, plainto_tsquery('simple', $1), 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')
+        END AS highlight`
+
+// broj pregleda filma u poslednjih 7 dana (iz "movie_view_stats" tabele), koristi se za "popularity" sortiranje
+// računa se samo kada je to sortiranje traženo - u suprotnom se umjesto ove kolone bira "0"
+const moviePopularityColumn = `
+        (SELECT COALESCE(sum(views), 0) FROM movie_view_stats s
+            WHERE s.movie_id = movies.id AND s.bucket >= date_trunc('hour', now() - interval '7 days'))::bigint AS popularity`
+
+// uslov za pretragu po naslovu ("$1")
+// "<%" operator je ispunjen kada je "word_similarity" veća od "pg_trgm.word_similarity_threshold" (podrazumijevano 0.6)
+// njega ubrzava "movies_title_trgm_idx" indeks
+//
+// pored originalnog naslova, pretražuju se i alternativni naslovi iz "movie_titles" tabele
+func movieTitleCondition(fuzzy bool) string {
+	if fuzzy {
+		return `($1 = '' OR to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 <% title
+            OR EXISTS (
+                SELECT 1 FROM movie_titles
+                WHERE movie_titles.movie_id = movies.id
+                AND (to_tsvector('simple', movie_titles.title) @@ plainto_tsquery('simple', $1) OR $1 <% movie_titles.title)
+            ))`
+	}
+
+	return `($1 = '' OR to_tsvector('simple', title) @@ plainto_tsquery('simple', $1)
+            OR EXISTS (
+                SELECT 1 FROM movie_titles
+                WHERE movie_titles.movie_id = movies.id
+                AND to_tsvector('simple', movie_titles.title) @@ plainto_tsquery('simple', $1)
+            ))`
+}
+
+// podržani "facet"-i i SQL upiti za njihovo računanje
+// svaki upit vraća kolone "facet", "value", "count" i "position" (redoslijed vrijednosti unutar "facet"-a)
+// "%s" se zamjenjuje istim "WHERE" uslovom koji koristi "GetAll()", pa brojevi uvijek odgovaraju trenutnim filterima
+var movieFacetQueries = map[string]string{
+	// jedan film se broji u svakom od svojih žanrova, a žanrovi se sortiraju po broju filmova
+	"genres": `
+        SELECT 'genres', genre, count(*), 0
+        FROM movies, unnest(genres) AS genre
+        WHERE %s
+        GROUP BY genre`,
+	// recimo, 1994 → "1990s"
+	"decade": `
+        SELECT 'decade', ((year / 10) * 10)::text || 's', count(*), year / 10
+        FROM movies
+        WHERE %s
+        GROUP BY year / 10`,
+	"runtime_bucket": `
+        SELECT 'runtime_bucket', bucket.label, count(*), bucket.position
+        FROM movies
+        CROSS JOIN LATERAL (
+            SELECT CASE WHEN runtime < 90 THEN '0-89' WHEN runtime < 120 THEN '90-119' WHEN runtime < 150 THEN '120-149' ELSE '150+' END AS label,
+                   CASE WHEN runtime < 90 THEN 1 WHEN runtime < 120 THEN 2 WHEN runtime < 150 THEN 3 ELSE 4 END AS position
+        ) AS bucket
+        WHERE %s
+        GROUP BY bucket.label, bucket.position`,
+}
+
+// broj filmova za jednu vrijednost "facet"-a (recimo, "drama": 12)
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+func ValidateFacets(v *validator.Validator, facets []string) {
+	for _, facet := range facets {
+		_, ok := movieFacetQueries[facet]
+		v.Check(ok, "facets", "must only contain genres, decade or runtime_bucket")
+	}
+
+	v.Check(validator.Unique(facets), "facets", "must not contain duplicate values")
+}
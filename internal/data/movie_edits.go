@@ -0,0 +1,284 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+// izmjena objavljenog (ili zakazanog) filma koja čeka odobrenje korisnika sa "movies:publish" permission-om
+// objavljena verzija filma ostaje vidljiva u katalogu sve dok se izmjena ne odobri ("approve") ili odbije ("reject")
+//
+// sadržaj je kompletan predlog novog stanja filma, pa svaka naredna izmjena nastavlja od prethodne (pogledati "ApplyTo()")
+// "nil" vrijednost za "ExternalIDs", "Titles" i "Releases" znači da se postojeće vrijednosti ne mijenjaju
+type MovieEdit struct {
+	MovieID     int64             `json:"movie_id"`
+	Title       string            `json:"title"`
+	Year        int32             `json:"year"`
+	Runtime     Runtime           `json:"runtime"`
+	Genres      []string          `json:"genres"`
+	ExternalKey string            `json:"external_key,omitempty"`
+	ExternalIDs map[string]string `json:"external_ids"`
+	Titles      []MovieTitle      `json:"titles"`
+	Releases    []MovieRelease    `json:"releases"`
+	UserID      int64             `json:"user_id"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// "editRequiresReview()" vraća "true" za statuse u kojima je film (ili će uskoro biti) javno vidljiv
+// izmjena takvog filma se ne primjenjuje odmah, već se čuva kao "MovieEdit" do odobrenja
+func editRequiresReview(status string) bool {
+	return status == MovieStatusPublished || status == MovieStatusScheduled
+}
+
+// "ApplyTo()" upisuje predloženi sadržaj u "movie", kako bi nova izmjena nastavila od izmjene koja već čeka odobrenje
+func (e *MovieEdit) ApplyTo(movie *Movie) {
+	movie.Title = e.Title
+	movie.Year = e.Year
+	movie.Runtime = e.Runtime
+	movie.Genres = e.Genres
+	movie.ExternalKey = e.ExternalKey
+	if e.ExternalIDs != nil {
+		movie.ExternalIDs = e.ExternalIDs
+	}
+}
+
+const movieEditColumns = `movie_id, title, year, runtime, genres, COALESCE(external_key, ''), external_ids, titles, releases, COALESCE(user_id, 0), updated_at`
+
+// "scanMovieEdit()" čita red sa kolonama iz "movieEditColumns"
+func scanMovieEdit(row interface{ Scan(dest ...any) error }) (*MovieEdit, error) {
+	var edit MovieEdit
+	var externalIDs, titles, releases []byte
+
+	err := row.Scan(
+		&edit.MovieID,
+		&edit.Title,
+		&edit.Year,
+		&edit.Runtime,
+		pq.Array(&edit.Genres),
+		&edit.ExternalKey,
+		&externalIDs,
+		&titles,
+		&releases,
+		&edit.UserID,
+		&edit.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	// "NULL" kolone ostaju "nil"
+	for _, field := range []struct {
+		src  []byte
+		dest any
+	}{
+		{externalIDs, &edit.ExternalIDs},
+		{titles, &edit.Titles},
+		{releases, &edit.Releases},
+	} {
+		if field.src == nil {
+			continue
+		}
+
+		err = json.Unmarshal(field.src, field.dest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &edit, nil
+}
+
+// "nullableJSON()" vraća JSON za "jsonb" kolonu, odnosno "NULL" za "nil" vrijednost
+func nullableJSON(value any, isNil bool) (any, error) {
+	if isNil {
+		return nil, nil
+	}
+
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(js), nil
+}
+
+// "GetPendingEdit()" vraća izmjenu filma koja čeka odobrenje ("ErrRecordNotFound" ukoliko je nema)
+func (m ReviewModel) GetPendingEdit(movieID int64) (*MovieEdit, error) {
+	query := `SELECT ` + movieEditColumns + ` FROM movie_pending_edits WHERE movie_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanMovieEdit(m.DB.QueryRowContext(ctx, query, movieID))
+}
+
+func getPendingEditTx(ctx context.Context, tx *sql.Tx, movieID int64) (*MovieEdit, error) {
+	query := `SELECT ` + movieEditColumns + ` FROM movie_pending_edits WHERE movie_id = $1`
+
+	return scanMovieEdit(tx.QueryRowContext(ctx, query, movieID))
+}
+
+// "savePendingEdit()" čuva predloženi sadržaj filma ("movie") kao izmjenu koja čeka odobrenje i upisuje "edit" akciju u istoriju
+// predložene lokalizacije iz ranije izmjene se zadržavaju
+// nakon toga "movie" ponovo sadrži objavljenu verziju filma, a "movie.PendingEdit" sačuvanu izmjenu
+func savePendingEdit(ctx context.Context, tx *sql.Tx, movie *Movie, userID int64) error {
+	externalIDs, err := nullableJSON(movie.ExternalIDs, movie.ExternalIDs == nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO movie_pending_edits (movie_id, title, year, runtime, genres, external_key, external_ids, user_id)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+        ON CONFLICT (movie_id) DO UPDATE
+        SET title = EXCLUDED.title, year = EXCLUDED.year, runtime = EXCLUDED.runtime, genres = EXCLUDED.genres,
+            external_key = EXCLUDED.external_key, external_ids = COALESCE(EXCLUDED.external_ids, movie_pending_edits.external_ids),
+            user_id = EXCLUDED.user_id, updated_at = NOW()`
+
+	args := []any{movie.ID, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.ExternalKey, externalIDs, userID}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	return finishPendingEdit(ctx, tx, movie, userID)
+}
+
+// "savePendingLocalizations()" čuva predložene lokalizacije kao izmjenu koja čeka odobrenje
+// ukoliko izmjena još ne postoji, predloženi sadržaj filma je njegova objavljena verzija
+func savePendingLocalizations(ctx context.Context, tx *sql.Tx, movie *Movie, userID int64, titles []MovieTitle, releases []MovieRelease) error {
+	titlesJSON, err := nullableJSON(titles, false)
+	if err != nil {
+		return err
+	}
+
+	releasesJSON, err := nullableJSON(releases, false)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO movie_pending_edits (movie_id, title, year, runtime, genres, external_key, titles, releases, user_id)
+        SELECT id, title, year, runtime, genres, external_key, $2, $3, $4
+        FROM movies
+        WHERE id = $1
+        ON CONFLICT (movie_id) DO UPDATE
+        SET titles = EXCLUDED.titles, releases = EXCLUDED.releases, user_id = EXCLUDED.user_id, updated_at = NOW()`
+
+	_, err = tx.ExecContext(ctx, query, movie.ID, titlesJSON, releasesJSON, userID)
+	if err != nil {
+		return err
+	}
+
+	return finishPendingEdit(ctx, tx, movie, userID)
+}
+
+// "finishPendingEdit()" upisuje "edit" akciju u istoriju, a objavljenu verziju filma i sačuvanu izmjenu vraća u "movie"
+// status filma se ne mijenja - izmjena se ne vidi u katalogu, pa se ne upisuju ni poruka u "outbox"-u ni događaj za "stream"
+func finishPendingEdit(ctx context.Context, tx *sql.Tx, movie *Movie, userID int64) error {
+	query := `
+        INSERT INTO movie_reviews (movie_id, user_id, action, from_status, to_status)
+        VALUES ($1, $2, $3, $4, $4)`
+
+	_, err := tx.ExecContext(ctx, query, movie.ID, userID, MovieReviewEdit, movie.Status)
+	if err != nil {
+		return err
+	}
+
+	published, err := getMovieTx(ctx, tx, movie.ID)
+	if err != nil {
+		return err
+	}
+
+	edit, err := getPendingEditTx(ctx, tx, movie.ID)
+	if err != nil {
+		return err
+	}
+
+	published.ExternalIDs, err = getExternalIDsTx(ctx, tx, movie.ID)
+	if err != nil {
+		return err
+	}
+
+	*movie = *published
+	movie.PendingEdit = edit
+
+	return nil
+}
+
+// "applyPendingEdit()" primjenjuje izmjenu koja čeka odobrenje na film i briše je
+// "ErrRecordNotFound" znači da film nema izmjenu koja čeka odobrenje
+func applyPendingEdit(ctx context.Context, tx *sql.Tx, movie *Movie) error {
+	query := `DELETE FROM movie_pending_edits WHERE movie_id = $1 RETURNING ` + movieEditColumns
+
+	edit, err := scanMovieEdit(tx.QueryRowContext(ctx, query, movie.ID))
+	if err != nil {
+		return err
+	}
+
+	query = `
+        UPDATE movies
+        SET title = $1, year = $2, runtime = $3, genres = $4, external_key = NULLIF($5, '')
+        WHERE id = $6`
+
+	args := []any{edit.Title, edit.Year, edit.Runtime, pq.Array(edit.Genres), edit.ExternalKey, movie.ID}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movies_external_key_key"`:
+			return ErrDuplicateExternalKey
+		default:
+			return err
+		}
+	}
+
+	if edit.ExternalIDs != nil {
+		err = replaceExternalIDs(ctx, tx, movie.ID, edit.ExternalIDs)
+		if err != nil {
+			return err
+		}
+		movie.ExternalIDs = edit.ExternalIDs
+	}
+
+	if edit.Titles != nil || edit.Releases != nil {
+		err = replaceLocalizations(ctx, tx, movie.ID, edit.Titles, edit.Releases)
+		if err != nil {
+			return err
+		}
+	}
+
+	edit.ApplyTo(movie)
+
+	return nil
+}
+
+// "discardPendingEdit()" briše izmjenu koja čeka odobrenje
+// "ErrRecordNotFound" znači da film nema izmjenu koja čeka odobrenje
+func discardPendingEdit(ctx context.Context, tx *sql.Tx, movieID int64) error {
+	result, err := tx.ExecContext(ctx, `DELETE FROM movie_pending_edits WHERE movie_id = $1`, movieID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
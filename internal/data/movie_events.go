@@ -0,0 +1,198 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
+	"strconv"
+	"time"
+)
+
+// "LISTEN/NOTIFY" kanal preko kog se objavljuju nove izmjene filmova
+// sadržaj obavještenja je samo "id" događaja - sam događaj se čita iz "movie_events" tabele (obavještenje je ograničeno na 8000 bajtova)
+const MovieEventsChannel = "movie_events"
+
+// koliko dugo se događaji čuvaju (i koliko daleko unazad klijent može da nastavi preko "Last-Event-ID" header-a)
+const MovieEventsRetention = 24 * time.Hour
+
+// ključ "advisory lock"-a koji serijalizuje upise u "movie_events" (pogledati "insertMovieEvent()")
+const movieEventsLockKey = 7_001_050
+
+// jedna izmjena filma ("movie.created", "movie.updated" ili "movie.deleted")
+// "Data" je film nakon izmjene, a za brisanje samo {"id": 1}
+type MovieEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	MovieID   int64           `json:"movie_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// "MovieEventModel" služi za čitanje "movie_events" tabele
+// događaje upisuju modeli koji mijenjaju filmove (preko "insertMovieEvent()"), unutar svoje transakcije
+type MovieEventModel struct {
+	DB *sql.DB
+}
+
+// događaj koji se upisuje preko "insertMovieEvents()"
+type movieEventInput struct {
+	Type    string
+	MovieID int64
+	Data    any
+}
+
+// "insertMovieEvent()" upisuje jedan događaj unutar postojeće transakcije (pogledati "insertMovieEvents()")
+func insertMovieEvent(ctx context.Context, tx *sql.Tx, eventType string, movieID int64, data any) error {
+	return insertMovieEvents(ctx, tx, []movieEventInput{{Type: eventType, MovieID: movieID, Data: data}})
+}
+
+// "insertMovieEvents()" upisuje događaje unutar postojeće transakcije i šalje "NOTIFY" sa "id"-em poslednjeg od njih
+// PostgreSQL isporučuje obavještenje tek nakon potvrde transakcije, a ukoliko se transakcija poništi - ni događaji ni obavještenje ne postoje
+//
+// čitaoci loga napreduju preko "id > lastID", pa događaji moraju da postanu vidljivi redom kojim su dobili "id"
+// bez zaključavanja, transakcija sa manjim "id"-em može da bude potvrđena nakon one sa većim, pa bi čitalac njen događaj preskočio
+// zato se prije upisa preuzima "advisory lock" koji važi do kraja transakcije - upisi u log se izvršavaju jedan po jedan
+//
+// BITNO:
+// lock se drži do potvrde transakcije, pa ovu funkciju treba pozvati jednom, na kraju transakcije (nakon svih ostalih izmjena)
+// transakcija koja mijenja više filmova ("InsertBatch()", promjena "slug"-a žanra,...) sve događaje upisuje jednim upitom
+// na taj način lock traje samo koliko i taj upit i "COMMIT", ali se transakcije koje upisuju događaje i dalje potvrđuju jedna po jedna
+// (propusnost loga je ograničena trajanjem "COMMIT"-a - reda veličine nekoliko stotina transakcija u sekundi)
+func insertMovieEvents(ctx context.Context, tx *sql.Tx, events []movieEventInput) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	// JSON se priprema prije preuzimanja lock-a
+	types := make([]string, len(events))
+	movieIDs := make([]int64, len(events))
+	payloads := make([]string, len(events))
+
+	for i, event := range events {
+		js, err := json.Marshal(event.Data)
+		if err != nil {
+			return err
+		}
+
+		types[i], movieIDs[i], payloads[i] = event.Type, event.MovieID, string(js)
+	}
+
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", movieEventsLockKey)
+	if err != nil {
+		return err
+	}
+
+	// "ORDER BY ordinality" obezbjeđuje da događaji dobiju "id" redom kojim su proslijeđeni
+	query := `
+        INSERT INTO movie_events (type, movie_id, data)
+        SELECT type, movie_id, data
+        FROM unnest($1::text[], $2::bigint[], $3::jsonb[]) WITH ORDINALITY AS e(type, movie_id, data, ordinality)
+        ORDER BY ordinality
+        RETURNING id`
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(types), pq.Array(movieIDs), pq.Array(payloads))
+	if err != nil {
+		return err
+	}
+
+	var lastID int64
+
+	for rows.Next() {
+		var id int64
+
+		err := rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		lastID = max(lastID, id)
+	}
+
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	// čitaoci nakon obavještenja čitaju sve nove događaje, pa je jedno obavještenje dovoljno za čitavu transakciju
+	_, err = tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", MovieEventsChannel, strconv.FormatInt(lastID, 10))
+	return err
+}
+
+// "LatestID()" vraća "id" poslednjeg upisanog događaja (nula ukoliko log nema događaja)
+func (m MovieEventModel) LatestID() (int64, error) {
+	query := `
+        SELECT COALESCE(MAX(id), 0)
+        FROM movie_events`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+
+	err := m.DB.QueryRowContext(ctx, query).Scan(&id)
+	return id, err
+}
+
+// "GetSince()" vraća najviše "limit" događaja upisanih nakon događaja "afterID", redom kojim su upisani
+func (m MovieEventModel) GetSince(afterID int64, limit int) ([]*MovieEvent, error) {
+	query := `
+        SELECT id, type, movie_id, data, created_at
+        FROM movie_events
+        WHERE id > $1
+        ORDER BY id
+        LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*MovieEvent
+
+	for rows.Next() {
+		var event MovieEvent
+
+		err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.MovieID,
+			&event.Data,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// "DeleteExpired()" briše događaje starije od "MovieEventsRetention" i vraća broj obrisanih događaja
+func (m MovieEventModel) DeleteExpired() (int64, error) {
+	query := `
+        DELETE FROM movie_events
+        WHERE created_at < NOW() - make_interval(secs => $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, MovieEventsRetention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -7,6 +7,9 @@ import (
 	"fmt"
 	"github.com/lib/pq"
 	validator "greenlight.lazarmrkic.com/internal"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,9 +20,36 @@ type Movie struct {
 	Year      int32     `json:"year,omitempty"`
 	Runtime   Runtime   `json:"runtime,omitempty"`
 	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"`
+	// identifikator koji dodjeljuje klijent (recimo, "ingestion pipeline"), jedinstven za svaki film
+	// preko njega se vrši "upsert" bez prethodnog traženja "ID"-a
+	ExternalKey string `json:"external_key,omitempty"`
+	// identifikatori u spoljnim katalozima, po izvoru (recimo {"imdb": "tt0111161"})
+	// "nil" vrijednost prilikom ažuriranja znači da se postojeći identifikatori ne mijenjaju
+	ExternalIDs map[string]string `json:"external_ids,omitempty"`
+	Version     int32             `json:"version"`
+	// faza uređivačkog procesa ("draft", "in_review", "scheduled", "published", "archived")
+	// korisnici koji samo čitaju filmove vide isključivo objavljene ("published") filmove
+	Status string `json:"status"`
+	// vremenski okvir u kom je objavljen film vidljiv korisnicima koji samo čitaju katalog ("nil" - bez ograničenja)
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty"`
+	// polja koja se popunjavaju samo prilikom pretrage po naslovu ("GetAll" sa "title" filterom):
+	// "Relevance" je ocjena poklapanja, a "Highlight" naslov u kom su pronađene riječi označene "<mark>" tagovima
+	Relevance float64 `json:"relevance,omitempty"`
+	Highlight string  `json:"highlight,omitempty"`
+	// broj pregleda u poslednjih 7 dana, popunjava se samo prilikom "popularity" sortiranja
+	Popularity int64 `json:"popularity,omitempty"`
+	// naslov na jeziku koji je klijent tražio ("Accept-Language" ili "locale" parametar), ukoliko postoji
+	LocalizedTitle string `json:"localized_title,omitempty"`
+	// izmjena koja čeka odobrenje, popunjava se samo nakon izmjene objavljenog filma (pogledati "MovieEdit")
+	// ne serijalizuje se zajedno sa filmom, kako ne bi stigla do korisnika koji samo čitaju katalog ("outbox", "stream")
+	PendingEdit *MovieEdit `json:"-"`
 }
 
+var (
+	ErrDuplicateExternalKey = errors.New("duplicate external key")
+)
+
 // "MovieModel" struct omotava "sql.DB" connection pool"
 // preko njega ćemo vršiti interakciju sa bazom
 // on će biti sadržan unutar "Models" struct-a
@@ -38,7 +68,7 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 
 	// "pg_sleep" će simulirati kašnjenje pri radu sa bazom
 	query := `
-        SELECT id, created_at, title, year, runtime, genres, version
+        SELECT id, created_at, title, year, runtime, genres, COALESCE(external_key, ''), version, status, publish_at, unpublish_at
         FROM movies
         WHERE id = $1`
 
@@ -64,7 +94,11 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		&movie.Runtime,
 		// mora da se koristi "pq.Array()", jer se skenira "text[]" niz:
 		pq.Array(&movie.Genres),
+		&movie.ExternalKey,
 		&movie.Version,
+		&movie.Status,
+		&movie.PublishAt,
+		&movie.UnpublishAt,
 	)
 
 	if err != nil {
@@ -76,34 +110,399 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		}
 	}
 
+	err = m.loadExternalIDs(&movie)
+	if err != nil {
+		return nil, err
+	}
+
+	return &movie, nil
+}
+
+// "getMovieTx()" čita film unutar postojeće transakcije (bez spoljnih identifikatora)
+// koristi se kada je za poruku u "outbox"-u potrebno stanje filma nakon izmjene koju je napravio SQL upit (recimo, spajanje duplikata)
+func getMovieTx(ctx context.Context, tx *sql.Tx, id int64) (*Movie, error) {
+	query := `
+        SELECT id, created_at, title, year, runtime, genres, COALESCE(external_key, ''), version, status, publish_at, unpublish_at
+        FROM movies
+        WHERE id = $1`
+
+	var movie Movie
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.ExternalKey,
+		&movie.Version,
+		&movie.Status,
+		&movie.PublishAt,
+		&movie.UnpublishAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return &movie, nil
 }
 
 // "Insert" metoda prima "*Movie" pointer, pa se nakon poziva "Scan()" metode ažuriraju vrijednosti na lokaciji na koju pointer pokazuje
 func (m MovieModel) Insert(movie *Movie) error {
 	query := `
-        INSERT INTO movies (title, year, runtime, genres) 
-        VALUES ($1, $2, $3, $4)
-        RETURNING id, created_at, version`
+        INSERT INTO movies (title, year, runtime, genres, external_key) 
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+        RETURNING id, created_at, version, status`
 
 	// ovdje će biti definisane vrijednosti koje idu u "placeholder" parametre
 	// BITNO:
 	// niz žanrova će biti ubačen preko "pq.Array()"
 	// preko ove metode možemo da ubacujemo i ostale nizove različitih tipova (bool, byte, int32, int64,...)
-	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.ExternalKey}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	// film, njegovi spoljni identifikatori, "movie.created" poruka i događaj za "stream" se upisuju unutar jedne transakcije
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	// koristi se "QueryRow()" jer nam upit vraća jedan red podataka
 	// naš "INSERT" treba da vrati tri reda - "ID" / "CreatedAt" i "Version"
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version, &movie.Status)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movies_external_key_key"`:
+			return ErrDuplicateExternalKey
+		default:
+			return err
+		}
+	}
+
+	err = replaceExternalIDs(ctx, tx, movie.ID, movie.ExternalIDs)
+	if err != nil {
+		return err
+	}
+
+	err = insertOutbox(ctx, tx, OutboxMovieCreated, movie)
+	if err != nil {
+		return err
+	}
+
+	err = insertMovieEvent(ctx, tx, "movie.created", movie.ID, movie)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// "InsertBatch()" ubacuje više filmova unutar jedne transakcije i vraća grešku za svaki red posebno
+// svaki red se ubacuje unutar zasebnog "savepoint"-a, pa greška u jednom redu (recimo, duplikat "external_key") ne prekida ostatak transakcije
+//
+// ukoliko je "atomic" postavljen na "true", transakcija se poništava čim bilo koji red ima grešku (sve ili ništa)
+// u suprotnom, čuvaju se svi redovi koji su uspješno ubačeni ("best-effort")
+func (m MovieModel) InsertBatch(movies []*Movie, atomic bool) ([]error, error) {
+	query := `
+        INSERT INTO movies (title, year, runtime, genres, external_key) 
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+        RETURNING id, created_at, version, status`
+
+	// za razliku od pojedinačnih upita, "batch" ima na raspolaganju 30 sekundi, uz dodatnih 20ms po redu
+	// ("atomic" uvoz čuva čitav fajl unutar jedne transakcije, pa fiksni rok ne bi zavisio od veličine fajla)
+	timeout := 30*time.Second + time.Duration(len(movies))*20*time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// "Rollback()" nema efekta ukoliko je transakcija već potvrđena preko "Commit()"
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rowErrors := make([]error, len(movies))
+	failed := false
+
+	for i, movie := range movies {
+		_, err = tx.ExecContext(ctx, "SAVEPOINT insert_batch_row")
+		if err != nil {
+			return nil, err
+		}
+
+		args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.ExternalKey}
+
+		err = stmt.QueryRowContext(ctx, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version, &movie.Status)
+		if err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "movies_external_key_key"`:
+				rowErrors[i] = ErrDuplicateExternalKey
+			default:
+				rowErrors[i] = err
+			}
+			failed = true
+
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT insert_batch_row")
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		err = insertOutbox(ctx, tx, OutboxMovieCreated, movie)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT insert_batch_row")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if atomic && failed {
+		return rowErrors, nil
+	}
+
+	// događaji za "stream" se upisuju tek nakon svih redova i jednim upitom, jer "insertMovieEvents()" zaključava log do kraja transakcije
+	var events []movieEventInput
+
+	for i, movie := range movies {
+		if rowErrors[i] == nil {
+			events = append(events, movieEventInput{Type: "movie.created", MovieID: movie.ID, Data: movie})
+		}
+	}
+
+	err = insertMovieEvents(ctx, tx, events)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return rowErrors, nil
+}
+
+// ishod "Upsert()" metode
+const (
+	UpsertCreated   = "created"
+	UpsertUpdated   = "updated"
+	UpsertUnchanged = "unchanged"
+	// objavljen ili zakazan film se ne mijenja, već se zamjena čuva kao izmjena koja čeka odobrenje
+	UpsertPending = "pending"
+)
+
+// "Upsert()" kreira novi film ili u potpunosti zamjenjuje postojeći film sa istim "ExternalKey" vrijednošću
+// povratna vrijednost govori da li je zapis kreiran, zamijenjen, ostao isti ili čeka odobrenje
+// ("UpsertCreated", "UpsertUpdated", "UpsertUnchanged", "UpsertPending")
+//
+// ukoliko se sadržaj filma nije promijenio, zapis se ne dira i "version" ostaje ista
+// na taj način, ponovljeni "upsert" sa istim podacima je idempotentan
+//
+// kao i kod "Update()", zamjena objavljenog ili zakazanog filma se čuva kao izmjena koja čeka odobrenje (pogledati "upsertPublished()")
+func (m MovieModel) Upsert(movie *Movie, userID int64) (string, error) {
+	if movie.ExternalKey == "" {
+		return "", errors.New("upsert requires an external key")
+	}
+
+	// "xmax = 0" važi samo za redove koji su upravo ubačeni, pa preko toga razlikujemo "insert" od "update"
+	query := `
+        INSERT INTO movies (title, year, runtime, genres, external_key)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (external_key) DO UPDATE
+        SET title = EXCLUDED.title, year = EXCLUDED.year, runtime = EXCLUDED.runtime, genres = EXCLUDED.genres, version = movies.version + 1
+        WHERE (movies.title, movies.year, movies.runtime, movies.genres) IS DISTINCT FROM (EXCLUDED.title, EXCLUDED.year, EXCLUDED.runtime, EXCLUDED.genres)
+        RETURNING id, created_at, version, status, publish_at, unpublish_at, xmax = 0`
+
+	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.ExternalKey}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// film, poruka u "outbox"-u i događaj za "stream" se upisuju unutar jedne transakcije
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// postojeći film se zaključava, kako mu se status ne bi promijenio prije zamjene
+	var existingID int64
+	var existingStatus string
+
+	err = tx.QueryRowContext(ctx, `SELECT id, status FROM movies WHERE external_key = $1 FOR UPDATE`, movie.ExternalKey).Scan(&existingID, &existingStatus)
+	switch {
+	case err == nil && editRequiresReview(existingStatus):
+		return m.upsertPublished(ctx, tx, existingID, movie, userID)
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return "", err
+	}
+
+	var created bool
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version, &movie.Status, &movie.PublishAt, &movie.UnpublishAt, &created)
+	if err != nil {
+		switch {
+		// "WHERE" uslov nije ispunjen - film već postoji sa identičnim sadržajem, pa se samo učitavaju njegovi podaci
+		case errors.Is(err, sql.ErrNoRows):
+			existing, err := m.GetByExternalKey(movie.ExternalKey)
+			if err != nil {
+				return "", err
+			}
+			*movie = *existing
+			return UpsertUnchanged, nil
+		default:
+			return "", err
+		}
+	}
+
+	result := UpsertUpdated
+	if created {
+		result = UpsertCreated
+	}
+
+	err = insertOutbox(ctx, tx, "movie."+result, movie)
+	if err != nil {
+		return "", err
+	}
+
+	err = insertMovieEvent(ctx, tx, "movie."+result, movie.ID, movie)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+// "upsertPublished()" čuva zamjenu objavljenog ili zakazanog filma kao izmjenu koja čeka odobrenje
+// zamjena se poredi sa izmjenom koja već čeka odobrenje (ili sa objavljenom verzijom, ukoliko je nema),
+// pa je i ponovljeni "upsert" objavljenog filma idempotentan
+func (m MovieModel) upsertPublished(ctx context.Context, tx *sql.Tx, id int64, movie *Movie, userID int64) (string, error) {
+	current, err := getMovieTx(ctx, tx, id)
+	if err != nil {
+		return "", err
+	}
+
+	proposed := *current
+
+	edit, err := getPendingEditTx(ctx, tx, id)
+	switch {
+	case err == nil:
+		edit.ApplyTo(&proposed)
+	case !errors.Is(err, ErrRecordNotFound):
+		return "", err
+	}
+
+	if proposed.Title == movie.Title && proposed.Year == movie.Year && proposed.Runtime == movie.Runtime && slices.Equal(proposed.Genres, movie.Genres) {
+		current.ExternalIDs, err = getExternalIDsTx(ctx, tx, id)
+		if err != nil {
+			return "", err
+		}
+
+		*movie = *current
+		movie.PendingEdit = edit
+		return UpsertUnchanged, nil
+	}
+
+	query := `
+        UPDATE movies
+        SET version = version + 1
+        WHERE id = $1
+        RETURNING version`
+
+	err = tx.QueryRowContext(ctx, query, id).Scan(&proposed.Version)
+	if err != nil {
+		return "", err
+	}
+
+	// "upsert" ne mijenja spoljne identifikatore, pa se zadržavaju oni iz izmjene koja već čeka odobrenje
+	proposed.Title, proposed.Year, proposed.Runtime, proposed.Genres = movie.Title, movie.Year, movie.Runtime, movie.Genres
+	proposed.ExternalIDs = nil
+
+	err = savePendingEdit(ctx, tx, &proposed, userID)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	*movie = proposed
+	return UpsertPending, nil
+}
+
+// vraćanje filma na osnovu "ExternalKey" vrijednosti:
+func (m MovieModel) GetByExternalKey(externalKey string) (*Movie, error) {
+	query := `
+        SELECT id, created_at, title, year, runtime, genres, external_key, version, status, publish_at, unpublish_at
+        FROM movies
+        WHERE external_key = $1`
+
+	var movie Movie
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, externalKey).Scan(
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.ExternalKey,
+		&movie.Version,
+		&movie.Status,
+		&movie.PublishAt,
+		&movie.UnpublishAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = m.loadExternalIDs(&movie)
+	if err != nil {
+		return nil, err
+	}
+
+	return &movie, nil
 }
 
 // prilikom ažuriranja vrijednosti za "Movie" objekat, "id" i "createdAt" ne trebaju da budu modifikovani
 // klijent ne treba da pristupa "version" polju
 // međutim,u našem slučaju ćemo ipak mijenjati sve navedene vrijednosti
-func (m MovieModel) Update(movie *Movie) error {
+//
+// izmjena objavljenog ili zakazanog filma se ne primjenjuje odmah, već se čuva kao izmjena koja čeka odobrenje (pogledati "MovieEdit")
+// objavljena verzija ostaje u katalogu, a "movie" nakon toga sadrži objavljenu verziju i "PendingEdit"
+// "userID" se upisuje u istoriju
+func (m MovieModel) Update(movie *Movie, userID int64) error {
 	// nakon izvršavanja "query"-ja, "version" će biti uvećana za 1
 	// BITNO - DATA RACE CONDITION:
 	// dešava se kada dva klijenta pokušavaju da ažuriraju isti red u isto vrijeme
@@ -113,8 +512,8 @@ func (m MovieModel) Update(movie *Movie) error {
 	// ukoliko je vrijednost u međuvremenu izmijenjena - onda se "update" neće izvršiti i klijent će dobiti "error"
 	query := `
         UPDATE movies 
-        SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-        WHERE id = $5 AND version = $6
+        SET title = $1, year = $2, runtime = $3, genres = $4, external_key = NULLIF($5, ''), version = version + 1
+        WHERE id = $6 AND version = $7
         RETURNING version`
 
 	// "slice" sa vrijednostima za "placeholder" parametre:
@@ -123,6 +522,7 @@ func (m MovieModel) Update(movie *Movie) error {
 		movie.Year,
 		movie.Runtime,
 		pq.Array(movie.Genres),
+		movie.ExternalKey,
 		movie.ID,
 		movie.Version,
 	}
@@ -135,16 +535,69 @@ func (m MovieModel) Update(movie *Movie) error {
 	//
 	// ukoliko prilikom upita ne možemo da pronađemo red u tabeli, onda znamo da je on ili izbrisan ili se verzija u međuvremenu izmjenila
 	// u tom slučaju vraćamo "ErrEditConflict"
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// "version" se i dalje provjerava (i povećava), kako dvije izmjene koje čekaju odobrenje ne bi jedna drugu tiho poništile
+	if editRequiresReview(movie.Status) {
+		query = `
+            UPDATE movies
+            SET version = version + 1
+            WHERE id = $1 AND version = $2
+            RETURNING version`
+
+		err = tx.QueryRowContext(ctx, query, movie.ID, movie.Version).Scan(&movie.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+
+		err = savePendingEdit(ctx, tx, movie, userID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movies_external_key_key"`:
+			return ErrDuplicateExternalKey
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
 			return err
 		}
 	}
-	return nil
+
+	if movie.ExternalIDs != nil {
+		err = replaceExternalIDs(ctx, tx, movie.ID, movie.ExternalIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	// "movie.updated" poruka i događaj za "stream" se upisuju u istoj transakciji (pogledati "insertOutbox()" i "insertMovieEvent()")
+	err = insertOutbox(ctx, tx, OutboxMovieUpdated, movie)
+	if err != nil {
+		return err
+	}
+
+	err = insertMovieEvent(ctx, tx, "movie.updated", movie.ID, movie)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (m MovieModel) Delete(id int64) error {
@@ -159,9 +612,16 @@ func (m MovieModel) Delete(id int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	// brisanje, "movie.deleted" poruka i događaj za "stream" se upisuju unutar jedne transakcije
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	// koristimo "Exec()" metodu jer se nakon brisanja neće vratiti nijedan red
 	// međutim, ova metoda vraća "sql.Result" objekat (sadrži broj redova na koje je "query" uticao)
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -177,13 +637,79 @@ func (m MovieModel) Delete(id int64) error {
 		return ErrRecordNotFound
 	}
 
-	return nil
+	err = insertOutbox(ctx, tx, OutboxMovieDeleted, map[string]int64{"id": id})
+	if err != nil {
+		return err
+	}
+
+	err = insertMovieEvent(ctx, tx, "movie.deleted", id, map[string]int64{"id": id})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// "DeleteVersion()" briše zapis samo ukoliko on još uvijek ima zadatu verziju
+// koristi se za uslovno brisanje ("If-Match" header) - ukoliko je zapis u međuvremenu izmijenjen, vraća se "ErrEditConflict"
+func (m MovieModel) DeleteVersion(id int64, version int32) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+        DELETE FROM movies
+        WHERE id = $1 AND version = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id, version)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	err = insertOutbox(ctx, tx, OutboxMovieDeleted, map[string]int64{"id": id})
+	if err != nil {
+		return err
+	}
+
+	err = insertMovieEvent(ctx, tx, "movie.deleted", id, map[string]int64{"id": id})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // ova metoda će vraćati "Movie" slice
 // ona će da prihvata razne "filter" parametre, iako ih na početku nećemo koristiti
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
-	// oba filtera će biti "optional" ('' ili '{}')
+//
+// kriterijumi za filtriranje se nalaze u "MovieCriteria" struct-u
+// ukoliko je "criteria.Fuzzy" postavljen na "true", pored "full text" pretrage se koristi i "pg_trgm" sličnost
+// na taj način će recimo "godfater" pronaći "The Godfather"
+func (m MovieModel) GetAll(criteria MovieCriteria, filters Filters) ([]*Movie, Metadata, error) {
+	// "keyset" paginacija ima poseban upit (bez "OFFSET"-a i bez "count(*) OVER()")
+	if filters.UseCursor {
+		return m.getAllKeyset(criteria, filters)
+	}
+
+	// svi filteri su "optional" - u upit se dodaju samo oni koje je klijent poslao
 	// "@>" predstavlja "contained by" operator u PostgreSQL
 	//
 	// PostgreSQL FULL TEXT SEARCH ("Natural Language Search")
@@ -201,20 +727,26 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	// "OFFSET" - preskače određeni broj redova prije nego što se redovi iz trenutnog upita vrate
 	//
 	// "window" funkcija vraća ukupan broj (isfiltriranih) redova
+	//
+	// "placeholder" parametri za filtere se nalaze u "args" slice-u, a "LIMIT" i "OFFSET" se dodaju na kraj
+	where, args := criteria.where()
+	args = append(args, filters.limit(), filters.offset())
+
+	popularity := "0 AS popularity"
+	if filters.Sort == "popularity" {
+		popularity = moviePopularityColumn
+	}
+
 	query := fmt.Sprintf(`
-        SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version
+        SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, COALESCE(external_key, ''), version, status, publish_at, unpublish_at, %s, %s
         FROM movies
-        WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '') 
-        AND (genres @> $2 OR $2 = '{}')     
+        WHERE %s
         ORDER BY %s %s, id ASC
-        LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+        LIMIT $%d OFFSET $%d`, movieSearchColumns, popularity, where, filters.sortColumn(), filters.sortDirection(), len(args)-1, len(args))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	// "placeholder" parametriće biti stavljeni u jedan "slice"
-	args := []any{title, pq.Array(genres), filters.limit(), filters.offset()}
-
 	// "QueryContext" metoda će vratiti "sql.Rows resultset" - koji sadrži rezultat
 	// u ovu metodu će se proslijediti "variadic" parametar "args"
 	rows, err := m.DB.QueryContext(ctx, query, args...)
@@ -243,7 +775,14 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 			&movie.Year,
 			&movie.Runtime,
 			pq.Array(&movie.Genres),
+			&movie.ExternalKey,
 			&movie.Version,
+			&movie.Status,
+			&movie.PublishAt,
+			&movie.UnpublishAt,
+			&movie.Relevance,
+			&movie.Highlight,
+			&movie.Popularity,
 		)
 
 		if err != nil {
@@ -263,7 +802,315 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	return movies, metadata, nil
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// "GetFacets()" vraća broj filmova po vrijednostima traženih "facet"-a ("genres", "decade", "runtime_bucket")
+// svi "facet"-i se računaju jednim upitom ("UNION ALL"), nad istim "WHERE" uslovom kao i "GetAll()"
+func (m MovieModel) GetFacets(criteria MovieCriteria, facets []string) (map[string][]FacetCount, error) {
+	where, args := criteria.where()
+
+	var parts []string
+	for _, facet := range facets {
+		parts = append(parts, fmt.Sprintf(movieFacetQueries[facet], where))
+	}
+
+	query := fmt.Sprintf(`
+        SELECT facet, value, count
+        FROM (%s
+        ) AS facets (facet, value, count, position)
+        ORDER BY facet, position, count DESC, value`, strings.Join(parts, "\n        UNION ALL"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// svaki traženi "facet" se nalazi u odgovoru, čak i kada nema nijednu vrijednost
+	result := make(map[string][]FacetCount, len(facets))
+	for _, facet := range facets {
+		result[facet] = []FacetCount{}
+	}
+
+	for rows.Next() {
+		var facet string
+		var count FacetCount
+
+		err := rows.Scan(&facet, &count.Value, &count.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		result[facet] = append(result[facet], count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// "keyset" (cursor) paginacija
+// umjesto "OFFSET"-a (koji mora da preskoči sve prethodne redove), nastavlja se od zapisa iz kursora preko "(kolona, id) > (vrijednost, id)" poređenja
+// na taj način je svaka stranica podjednako brza i rezultati se ne "pomjeraju" kada se u međuvremenu ubace novi redovi
+//
+// BITNO:
+// "id" kao "tiebreaker" se ovdje sortira u ISTOM smjeru kao i glavna kolona, kako bi poređenje parova vrijednosti bilo ispravno
+func (m MovieModel) getAllKeyset(criteria MovieCriteria, filters Filters) ([]*Movie, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	var c cursor
+	if filters.Cursor != "" {
+		var err error
+		c, err = decodeCursor(filters.Cursor)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
+	// za prethodnu stranicu se redovi čitaju unazad (obrnut smjer sortiranja), a nakon toga se vraća prvobitni redoslijed
+	scanDirection := direction
+	if c.Prev {
+		scanDirection = map[string]string{"ASC": "DESC", "DESC": "ASC"}[direction]
+	}
+
+	operator := ">"
+	if scanDirection == "DESC" {
+		operator = "<"
+	}
+
+	where, args := criteria.where()
+
+	// prva stranica nema kursor, pa nema ni "keyset" uslova
+	if filters.Cursor != "" {
+		args = append(args, c.Value, c.ID)
+		where += fmt.Sprintf("\n        AND (%s, id) %s ($%d, $%d)", column, operator, len(args)-1, len(args))
+	}
+
+	// čita se jedan red više od "page_size", kako bismo znali da li postoji naredna stranica
+	args = append(args, filters.limit()+1)
+
+	query := fmt.Sprintf(`
+        SELECT id, created_at, title, year, runtime, genres, COALESCE(external_key, ''), version, status, publish_at, unpublish_at, %s
+        FROM movies
+        WHERE %s
+        ORDER BY %s %s, id %s
+        LIMIT $%d`, movieSearchColumns, where, column, scanDirection, scanDirection, len(args))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.ExternalKey,
+			&movie.Version,
+			&movie.Status,
+			&movie.PublishAt,
+			&movie.UnpublishAt,
+			&movie.Relevance,
+			&movie.Highlight,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	// da li postoji još redova u smjeru čitanja:
+	more := len(movies) > filters.limit()
+	if more {
+		movies = movies[:filters.limit()]
+	}
+
+	if c.Prev {
+		slices.Reverse(movies)
+	}
+
+	metadata := Metadata{PageSize: filters.PageSize}
+
+	if len(movies) > 0 {
+		first, last := movies[0], movies[len(movies)-1]
+
+		// naredna stranica postoji ukoliko ima još redova "unaprijed" ili ukoliko smo do ove stranice došli vraćanjem unazad
+		if more || c.Prev {
+			metadata.NextCursor = encodeCursor(cursor{Sort: filters.Sort, Value: movieSortValue(last, column), ID: last.ID})
+		}
+		// prethodna stranica postoji ukoliko smo do ove stranice došli preko kursora ili ukoliko ima još redova "unazad"
+		if (!c.Prev && filters.Cursor != "") || (c.Prev && more) {
+			metadata.PrevCursor = encodeCursor(cursor{Sort: filters.Sort, Value: movieSortValue(first, column), ID: first.ID, Prev: true})
+		}
+	}
+
+	return movies, metadata, nil
+}
+
+// vrijednost kolone po kojoj se sortira, u tekstualnom obliku (za potrebe kursora)
+// PostgreSQL će je konvertovati u odgovarajući tip prilikom poređenja sa kolonom
+func movieSortValue(movie *Movie, column string) string {
+	switch column {
+	case "title":
+		return movie.Title
+	case "year":
+		return strconv.Itoa(int(movie.Year))
+	case "runtime":
+		return strconv.Itoa(int(movie.Runtime))
+	default:
+		return strconv.FormatInt(movie.ID, 10)
+	}
+}
+
+// kratak prikaz filma za "autocomplete" prijedloge
+type MovieSuggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
+}
+
+// "Autocomplete()" vraća najviše "limit" prijedloga za dati tekst
+// prvo idu naslovi koji počinju zadatim tekstom ("movies_title_prefix_idx" indeks), a nakon njih slični naslovi ("movies_title_trgm_idx" indeks)
+// prijedlozi se keširaju zajednički za sve korisnike, pa sadrže samo objavljene filmove
+func (m MovieModel) Autocomplete(prefix string, limit int) ([]MovieSuggestion, error) {
+	// "%" i "_" imaju posebno značenje unutar "LIKE" izraza, pa ih je potrebno "escape"-ovati
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(prefix)) + "%"
+
+	query := `
+        SELECT id, title, year
+        FROM movies
+        WHERE (lower(title) LIKE $1 OR $2 <% title) AND ` + moviePublishedCondition + `
+        ORDER BY lower(title) LIKE $1 DESC, word_similarity($2, title) DESC, title ASC, id ASC
+        LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pattern, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []MovieSuggestion{}
+
+	for rows.Next() {
+		var suggestion MovieSuggestion
+
+		err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year)
+		if err != nil {
+			return nil, err
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// "Export()" prolazi kroz sve filmove koji odgovaraju kriterijumima (sortirani po "id"-u) i za svaki poziva "fn"
+// umjesto učitavanja svih zapisa u memoriju, koristi se "server-side" kursor i filmovi se dohvataju u grupama od po 500
+// ukoliko "fn" vrati grešku (recimo, klijent je prekinuo konekciju), iteracija se prekida i greška se vraća pozivaocu
+func (m MovieModel) Export(criteria MovieCriteria, fn func(movie *Movie) error) error {
+	// kursor mora da postoji unutar transakcije, koja traje koliko i sam "export"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	// kursor se automatski zatvara na kraju transakcije
+	defer tx.Rollback()
+
+	where, args := criteria.where()
+
+	query := fmt.Sprintf(`
+        DECLARE movies_export NO SCROLL CURSOR FOR
+        SELECT id, created_at, title, year, runtime, genres, COALESCE(external_key, ''), version, status, publish_at, unpublish_at
+        FROM movies
+        WHERE %s
+        ORDER BY id ASC`, where)
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rows, err := tx.QueryContext(ctx, "FETCH 500 FROM movies_export")
+		if err != nil {
+			return err
+		}
+
+		fetched := 0
+
+		for rows.Next() {
+			var movie Movie
+
+			err := rows.Scan(
+				&movie.ID,
+				&movie.CreatedAt,
+				&movie.Title,
+				&movie.Year,
+				&movie.Runtime,
+				pq.Array(&movie.Genres),
+				&movie.ExternalKey,
+				&movie.Version,
+				&movie.Status,
+				&movie.PublishAt,
+				&movie.UnpublishAt,
+			)
+			if err == nil {
+				err = fn(&movie)
+			}
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			fetched++
+		}
+
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		// kursor je došao do kraja rezultata:
+		if fetched == 0 {
+			return nil
+		}
+	}
+}
+
+func ValidateMovie(v *validator.Validator, movie *Movie, genres GenreTaxonomy) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
 
@@ -277,5 +1124,21 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(movie.Genres != nil, "genres", "must be provided")
 	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
+
+	// žanrovi moraju da postoje u referentnoj tabeli, a alias-i ("sci-fi") se zamjenjuju "slug"-om ("science-fiction")
+	for i, genre := range movie.Genres {
+		slug, ok := genres.Canonical(genre)
+		if !ok {
+			v.AddError("genres", "contains an unknown genre: "+genre)
+			continue
+		}
+		movie.Genres[i] = slug
+	}
+
+	// provjera duplikata se radi nakon normalizacije, pa "sci-fi" i "science-fiction" nisu dozvoljeni zajedno
 	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+
+	v.Check(len(movie.ExternalKey) <= 255, "external_key", "must not be more than 255 bytes long")
+
+	ValidateExternalIDs(v, movie.ExternalIDs)
 }
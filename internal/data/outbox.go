@@ -0,0 +1,197 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxDone    = "done"
+	OutboxFailed  = "failed"
+)
+
+// teme poruka koje se upisuju u "outbox" (svaka tema ima svoj "handler" u "dispatcher"-u)
+const (
+	OutboxUserRegistered = "user.registered"
+	OutboxUserActivated  = "user.activated"
+	OutboxMovieCreated   = "movie.created"
+	OutboxMovieUpdated   = "movie.updated"
+	OutboxMovieDeleted   = "movie.deleted"
+)
+
+// tema poruke o promjeni statusa filma (recimo "movie.published")
+func OutboxMovieStatus(status string) string {
+	return "movie." + status
+}
+
+const (
+	// najveći broj pokušaja obrade jedne poruke
+	OutboxMaxAttempts = 10
+	// pauza prije drugog pokušaja - svaki naredni pokušaj čeka duplo duže (10s, 20s, 40s,...), najviše "outboxMaxBackoff"
+	outboxBaseBackoff = 10 * time.Second
+	outboxMaxBackoff  = time.Hour
+	// koliko dugo je poruka rezervisana za instancu koja je preuzela (nakon toga je može preuzeti druga instanca)
+	outboxLease = 2 * time.Minute
+)
+
+// koliko dugo se čuvaju obrađene ("done") i neuspješne ("failed") poruke, nakon poslednjeg pokušaja
+const OutboxRetention = 7 * 24 * time.Hour
+
+// poruka iz "outbox"-a
+// "Payload" zavisi od teme: {"id": 1, "email": "..."} za registraciju korisnika, {"id": 1, "name": "..."} za aktivaciju,
+// film (kao JSON) za izmjene filmova,
+// a {"id": 1} za brisanje filma
+type OutboxMessage struct {
+	ID            int64           `json:"id"`
+	Topic         string          `json:"topic"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// pauza prije narednog pokušaja, nakon "attempts" neuspješnih pokušaja
+func outboxBackoff(attempts int) time.Duration {
+	backoff := float64(outboxBaseBackoff) * math.Pow(2, float64(attempts-1))
+	return time.Duration(min(backoff, float64(outboxMaxBackoff)))
+}
+
+// "OutboxModel" služi za preuzimanje i obradu poruka iz "outbox" tabele
+// poruke upisuju modeli same akcije (preko "insertOutbox()"), unutar svoje transakcije
+type OutboxModel struct {
+	DB *sql.DB
+}
+
+// "insertOutbox()" upisuje poruku unutar postojeće transakcije
+// poruka postaje vidljiva "dispatcher"-u tek kada se transakcija potvrdi, a ukoliko se poništi - poruka ne postoji
+func insertOutbox(ctx context.Context, tx *sql.Tx, topic string, payload any) error {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO outbox (topic, payload)
+        VALUES ($1, $2)`
+
+	_, err = tx.ExecContext(ctx, query, topic, string(js))
+	return err
+}
+
+// "ClaimDue()" preuzima najviše "limit" poruka koje čekaju obradu i rezerviše ih (pomjera "next_attempt_at" za "outboxLease")
+// "SKIP LOCKED" preskače poruke koje upravo preuzima druga instanca, pa više instanci može da radi istovremeno
+// poruke se vraćaju redom kojim su upisane
+func (m OutboxModel) ClaimDue(limit int) ([]*OutboxMessage, error) {
+	query := `
+        UPDATE outbox
+        SET next_attempt_at = NOW() + make_interval(secs => $2)
+        WHERE id IN (
+            SELECT id
+            FROM outbox
+            WHERE status = 'pending' AND next_attempt_at <= NOW()
+            ORDER BY next_attempt_at, id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id, topic, payload, status, attempts, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, outboxLease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*OutboxMessage
+
+	for rows.Next() {
+		var message OutboxMessage
+
+		err := rows.Scan(
+			&message.ID,
+			&message.Topic,
+			&message.Payload,
+			&message.Status,
+			&message.Attempts,
+			&message.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, &message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// "RETURNING" ne garantuje redosljed
+	slices.SortFunc(messages, func(a, b *OutboxMessage) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return messages, nil
+}
+
+// "RecordAttempt()" bilježi rezultat jednog pokušaja obrade:
+//   - uspješno obrađena poruka se zatvara ("done")
+//   - neuspješna poruka se ponovo zakazuje (eksponencijalni "backoff") ili, nakon "OutboxMaxAttempts" pokušaja, označava kao "failed"
+func (m OutboxModel) RecordAttempt(message *OutboxMessage, attemptErr error) error {
+	message.Attempts++
+	message.LastError = ""
+	message.Status = OutboxDone
+	message.NextAttemptAt = time.Now()
+
+	if attemptErr != nil {
+		message.LastError = attemptErr.Error()
+		message.Status = OutboxPending
+		message.NextAttemptAt = time.Now().Add(outboxBackoff(message.Attempts))
+
+		if message.Attempts >= OutboxMaxAttempts {
+			message.Status = OutboxFailed
+		}
+	}
+
+	query := `
+        UPDATE outbox
+        SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4,
+            processed_at = CASE WHEN $1 = 'pending' THEN NULL ELSE NOW() END
+        WHERE id = $5`
+
+	args := []any{message.Status, message.Attempts, message.NextAttemptAt, message.LastError, message.ID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// "DeleteExpired()" briše obrađene i neuspješne poruke starije od "OutboxRetention" i vraća broj obrisanih poruka
+// poruke koje još čekaju ("pending") se ne brišu, bez obzira na starost
+func (m OutboxModel) DeleteExpired() (int64, error) {
+	query := `
+        DELETE FROM outbox
+        WHERE status <> 'pending' AND processed_at < NOW() - make_interval(secs => $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, OutboxRetention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
+	validator "greenlight.lazarmrkic.com/internal"
+	"time"
+)
+
+// sažetak ocjena za jedan film
+type RatingSummary struct {
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
+
+// "RatingModel" služi za interakciju sa "ratings" tabelom
+type RatingModel struct {
+	DB *sql.DB
+}
+
+// "GetSummaries()" vraća prosječnu ocjenu i broj ocjena za svaki od zadatih filmova
+// filmovi bez ocjena se ne nalaze u mapi
+func (m RatingModel) GetSummaries(movieIDs []int64) (map[int64]RatingSummary, error) {
+	query := `
+        SELECT movie_id, avg(rating)::float8, count(*)
+        FROM ratings
+        WHERE movie_id = ANY($1)
+        GROUP BY movie_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make(map[int64]RatingSummary)
+
+	for rows.Next() {
+		var movieID int64
+		var summary RatingSummary
+
+		err := rows.Scan(&movieID, &summary.Average, &summary.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		summaries[movieID] = summary
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+// ocjena jednog korisnika za jedan film (od 1 do 10)
+type Rating struct {
+	UserID    int64     `json:"user_id"`
+	MovieID   int64     `json:"movie_id"`
+	Rating    int       `json:"rating"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func ValidateRating(v *validator.Validator, rating *Rating) {
+	v.Check(rating.Rating >= 1 && rating.Rating <= 10, "rating", "must be between 1 and 10")
+}
+
+// "Upsert()" upisuje ocjenu ili mijenja postojeću ocjenu korisnika za isti film
+// ocjena se bilježi i kao aktivnost korisnika (unutar iste transakcije)
+func (m RatingModel) Upsert(rating *Rating) error {
+	query := `
+        INSERT INTO ratings (user_id, movie_id, rating)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id, movie_id) DO UPDATE
+        SET rating = EXCLUDED.rating, created_at = NOW()
+        RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, rating.UserID, rating.MovieID, rating.Rating).Scan(&rating.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	details, err := json.Marshal(map[string]int{"rating": rating.Rating})
+	if err != nil {
+		return err
+	}
+
+	err = insertActivity(ctx, tx, &Activity{
+		UserID:  rating.UserID,
+		Type:    ActivityRating,
+		MovieID: &rating.MovieID,
+		Data:    details,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m RatingModel) Delete(userID, movieID int64) error {
+	query := `
+        DELETE FROM ratings
+        WHERE user_id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// filmovi koje je korisnik označio kao odgledane
+func (m RatingModel) MarkWatched(userID, movieID int64) error {
+	query := `
+        INSERT INTO watched_movies (user_id, movie_id)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, movieID)
+	return err
+}
+
+func (m RatingModel) UnmarkWatched(userID, movieID int64) error {
+	query := `
+        DELETE FROM watched_movies
+        WHERE user_id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, movieID)
+	return err
+}
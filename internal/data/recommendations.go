@@ -0,0 +1,187 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	// najmanji broj korisnika koji su ocijenili oba filma da bi se sličnost uopšte računala
+	similarityMinSupport = 2
+	// najviše sličnih filmova koji se čuvaju za svaki film
+	similarityNeighbours = 50
+	// "shrinkage" - sličnost zasnovana na malom broju zajedničkih ocjena se umanjuje
+	similarityShrinkage = 10
+	// broj ocjena nakon kog korisnikove ocjene i popularnost filma podjednako utiču na preporuke
+	coldStartRatings = 5
+)
+
+// preporučeni film, skupa sa ocjenom preporuke i razlogom
+// "reason" je "rated" (film je sličan filmovima koje je korisnik dobro ocijenio) ili "popular"
+type Recommendation struct {
+	ID      int64    `json:"id"`
+	Title   string   `json:"title"`
+	Year    int32    `json:"year"`
+	Runtime Runtime  `json:"runtime"`
+	Genres  []string `json:"genres"`
+	Score   float64  `json:"score"`
+	Reason  string   `json:"reason"`
+}
+
+// "RecommendationModel" služi za interakciju sa "movie_similarities" tabelom
+type RecommendationModel struct {
+	DB *sql.DB
+}
+
+// "RefreshSimilarities()" ponovo računa sličnost između filmova ("item-based collaborative filtering")
+//
+// ocjene se prvo centriraju oko prosjeka korisnika (korisnik koji svemu daje 9 i onaj koji svemu daje 5 nose istu informaciju),
+// a sličnost je kosinus ugla između vektora centriranih ocjena dva filma ("adjusted cosine")
+// za svaki film se čuva samo "similarityNeighbours" najsličnijih filmova
+//
+// tabela se puni unutar jedne transakcije, pa čitaoci sve do "COMMIT"-a vide prethodno izračunate vrijednosti
+func (m RecommendationModel) RefreshSimilarities(ctx context.Context) (int64, error) {
+	query := `
+        WITH centered AS (
+            SELECT user_id, movie_id, (rating - avg(rating) OVER (PARTITION BY user_id))::float8 AS rating
+            FROM ratings
+        ),
+        pairs AS (
+            SELECT a.movie_id, b.movie_id AS similar_movie_id,
+                sum(a.rating * b.rating) / NULLIF(sqrt(sum(a.rating ^ 2)) * sqrt(sum(b.rating ^ 2)), 0)
+                    * count(*) / (count(*) + $1) AS score,
+                count(*) AS support
+            FROM centered a
+            INNER JOIN centered b ON b.user_id = a.user_id AND b.movie_id <> a.movie_id
+            GROUP BY a.movie_id, b.movie_id
+            HAVING count(*) >= $2
+        )
+        INSERT INTO movie_similarities (movie_id, similar_movie_id, score, support)
+        SELECT movie_id, similar_movie_id, score, support
+        FROM (
+            SELECT *, row_number() OVER (PARTITION BY movie_id ORDER BY score DESC, similar_movie_id) AS rank
+            FROM pairs
+            WHERE score > 0
+        ) AS ranked
+        WHERE rank <= $3`
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_similarities`)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, query, similarityShrinkage, similarityMinSupport, similarityNeighbours)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, tx.Commit()
+}
+
+// "GetForUser()" vraća preporuke za korisnika, od najbolje ka najlošijoj
+//
+// ocjena preporuke spaja dva dijela:
+//   - predviđeno odstupanje od korisnikovog prosjeka, na osnovu sličnosti sa filmovima koje je ocijenio (od 0 do 1)
+//   - popularnost filma (Bayes-ov prosjek ocjena svih korisnika, od 0 do 1)
+//
+// udio prvog dijela raste sa brojem korisnikovih ocjena (n / (n + "coldStartRatings")),
+// pa novi korisnici bez ocjena dobijaju samo najpopularnije filmove
+//
+// filmovi koje je korisnik već ocijenio ili označio kao odgledane se izostavljaju, kao i neobjavljeni filmovi
+func (m RecommendationModel) GetForUser(userID int64, filters Filters) ([]*Recommendation, Metadata, error) {
+	query := fmt.Sprintf(`
+        WITH rated AS (
+            SELECT movie_id, (rating - avg(rating) OVER ())::float8 AS deviation
+            FROM ratings
+            WHERE user_id = $1
+        ),
+        weight AS (
+            SELECT count(*)::float8 / (count(*) + $2) AS value FROM rated
+        ),
+        collaborative AS (
+            SELECT s.similar_movie_id AS movie_id,
+                greatest(sum(s.score * rated.deviation) / sum(s.score), 0) / 9 AS score
+            FROM rated
+            INNER JOIN movie_similarities s ON s.movie_id = rated.movie_id
+            GROUP BY s.similar_movie_id
+        ),
+        popularity AS (
+            SELECT movie_id,
+                ((sum(rating) + $2 * (SELECT COALESCE(avg(rating), 0) FROM ratings)) / (count(*) + $2) / 10.0)::float8 AS score
+            FROM ratings
+            GROUP BY movie_id
+        )
+        SELECT count(*) OVER(), id, title, year, runtime, genres, score,
+            CASE WHEN collaborative_score > 0 THEN 'rated' ELSE 'popular' END
+        FROM (
+            SELECT m.id, m.title, m.year, m.runtime, m.genres,
+                COALESCE(c.score, 0) AS collaborative_score,
+                weight.value * COALESCE(c.score, 0) + (1 - weight.value) * COALESCE(p.score, 0) AS score
+            FROM movies m
+            CROSS JOIN weight
+            LEFT JOIN collaborative c ON c.movie_id = m.id
+            LEFT JOIN popularity p ON p.movie_id = m.id
+            WHERE (c.score > 0 OR p.score IS NOT NULL)
+            AND NOT EXISTS (SELECT 1 FROM ratings r WHERE r.user_id = $1 AND r.movie_id = m.id)
+            AND NOT EXISTS (SELECT 1 FROM watched_movies w WHERE w.user_id = $1 AND w.movie_id = m.id)
+            AND %s
+        ) AS candidates
+        ORDER BY score DESC, id ASC
+        LIMIT $3 OFFSET $4`, moviePublishedCondition)
+
+	args := []any{userID, coldStartRatings, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	recommendations := []*Recommendation{}
+
+	for rows.Next() {
+		var recommendation Recommendation
+
+		err := rows.Scan(
+			&totalRecords,
+			&recommendation.ID,
+			&recommendation.Title,
+			&recommendation.Year,
+			&recommendation.Runtime,
+			pq.Array(&recommendation.Genres),
+			&recommendation.Score,
+			&recommendation.Reason,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		recommendations = append(recommendations, &recommendation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return recommendations, metadata, nil
+}
@@ -0,0 +1,234 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	validator "greenlight.lazarmrkic.com/internal"
+	"time"
+)
+
+const (
+	MovieStatusDraft     = "draft"
+	MovieStatusInReview  = "in_review"
+	MovieStatusScheduled = "scheduled"
+	MovieStatusPublished = "published"
+	MovieStatusArchived  = "archived"
+)
+
+var MovieStatuses = []string{MovieStatusDraft, MovieStatusInReview, MovieStatusScheduled, MovieStatusPublished, MovieStatusArchived}
+
+// greška za akciju koja nije dozvoljena iz trenutnog statusa filma (recimo, "approve" nad "draft" filmom)
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// dozvoljeni prelazi - za svaku akciju su navedeni statusi iz kojih može da se pokrene i status u koji film prelazi
+// "submit" šalje film na recenziju, "approve" ga objavljuje, "reject" vraća u "draft", a "archive" uklanja objavljen film iz kataloga
+// odobren film sa budućim "publish_at" vremenom prelazi u "scheduled", a objavljuje ga "scheduler" (pogledati "ApplySchedules()")
+// "approve" i "reject" nad objavljenim ili zakazanim filmom primjenjuju, odnosno odbacuju izmjenu koja čeka odobrenje (pogledati "MovieEdit")
+var MovieTransitions = map[string]struct {
+	From []string
+	To   string
+}{
+	"submit":  {From: []string{MovieStatusDraft, MovieStatusArchived}, To: MovieStatusInReview},
+	"approve": {From: []string{MovieStatusInReview}, To: MovieStatusPublished},
+	"reject":  {From: []string{MovieStatusInReview}, To: MovieStatusDraft},
+	"archive": {From: []string{MovieStatusScheduled, MovieStatusPublished}, To: MovieStatusArchived},
+}
+
+// akcija koja se upisuje u istoriju kada se sačuva izmjena objavljenog (ili zakazanog) filma koja čeka odobrenje (pogledati "MovieEdit")
+const MovieReviewEdit = "edit"
+
+// jedan prelaz u istoriji filma
+type MovieReview struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	MovieID    int64     `json:"movie_id"`
+	UserID     int64     `json:"user_id"`
+	Action     string    `json:"action"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Comment    string    `json:"comment"`
+}
+
+func ValidateMovieReview(v *validator.Validator, review *MovieReview) {
+	// odbijanje mora da sadrži obrazloženje za autora
+	v.Check(review.Action != "reject" || review.Comment != "", "comment", "must be provided when rejecting a movie")
+	v.Check(len(review.Comment) <= 2000, "comment", "must not be more than 2000 bytes long")
+}
+
+// "ReviewModel" služi za promjenu statusa filmova i interakciju sa "movie_reviews" tabelom
+type ReviewModel struct {
+	DB *sql.DB
+}
+
+// "Transition()" izvršava akciju nad filmom i upisuje je u istoriju unutar iste transakcije
+// kao i "MovieModel.Update()", koristi "optimistic locking" preko "version" kolone
+func (m ReviewModel) Transition(movie *Movie, review *MovieReview) error {
+	transition, ok := MovieTransitions[review.Action]
+	if !ok {
+		return ErrInvalidTransition
+	}
+
+	// odobravanje ili odbijanje izmjene objavljenog filma ne mijenja njegov status
+	pendingEdit := validator.PermittedValue(review.Action, "approve", "reject") && editRequiresReview(movie.Status)
+
+	if !pendingEdit && !validator.PermittedValue(movie.Status, transition.From...) {
+		return ErrInvalidTransition
+	}
+
+	review.MovieID = movie.ID
+	review.FromStatus = movie.Status
+	review.ToStatus = transition.To
+	if review.ToStatus == MovieStatusPublished && movie.PublishAt != nil && movie.PublishAt.After(time.Now()) {
+		review.ToStatus = MovieStatusScheduled
+	}
+	if pendingEdit {
+		review.ToStatus = movie.Status
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        UPDATE movies
+        SET status = $1, version = version + 1
+        WHERE id = $2 AND version = $3
+        RETURNING version`
+
+	err = tx.QueryRowContext(ctx, query, review.ToStatus, movie.ID, movie.Version).Scan(&movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	query = `
+        INSERT INTO movie_reviews (movie_id, user_id, action, from_status, to_status, comment)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at`
+
+	args := []any{review.MovieID, review.UserID, review.Action, review.FromStatus, review.ToStatus, review.Comment}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	movie.Status = review.ToStatus
+
+	// arhiviran film više nije javno vidljiv, pa njegova izmjena koja čeka odobrenje gubi smisao
+	// izmjene arhiviranog filma se primjenjuju odmah, a film se ponovo objavljuje tek nakon nove recenzije
+	if !editRequiresReview(movie.Status) {
+		_, err = tx.ExecContext(ctx, `DELETE FROM movie_pending_edits WHERE movie_id = $1`, movie.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// film bez izmjene koja čeka odobrenje nema šta da se odobri ili odbije
+	if pendingEdit {
+		return m.finishPendingEditReview(ctx, tx, movie, review.Action)
+	}
+
+	// poruka o novom statusu (recimo "movie.published") i događaj za "stream" se upisuju u istoj transakciji
+	// "stream" ne razlikuje statuse - za njega je promjena statusa samo izmjena filma
+	err = insertOutbox(ctx, tx, OutboxMovieStatus(review.ToStatus), movie)
+	if err != nil {
+		return err
+	}
+
+	err = insertMovieEvent(ctx, tx, "movie.updated", movie.ID, movie)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// "finishPendingEditReview()" primjenjuje ("approve") ili odbacuje ("reject") izmjenu objavljenog filma unutar transakcije iz "Transition()"
+// odobrena izmjena je za čitaoce kataloga obična izmjena filma, pa se upisuju "movie.updated" poruka i događaj za "stream"
+func (m ReviewModel) finishPendingEditReview(ctx context.Context, tx *sql.Tx, movie *Movie, action string) error {
+	var err error
+	if action == "approve" {
+		err = applyPendingEdit(ctx, tx, movie)
+	} else {
+		err = discardPendingEdit(ctx, tx, movie.ID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrInvalidTransition
+		default:
+			return err
+		}
+	}
+
+	if action == "approve" {
+		err = insertOutbox(ctx, tx, OutboxMovieUpdated, movie)
+		if err != nil {
+			return err
+		}
+
+		err = insertMovieEvent(ctx, tx, "movie.updated", movie.ID, movie)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// "GetAllForMovie()" vraća istoriju prelaza za film, od najnovijeg ka najstarijem
+func (m ReviewModel) GetAllForMovie(movieID int64) ([]*MovieReview, error) {
+	query := `
+        SELECT id, created_at, movie_id, COALESCE(user_id, 0), action, from_status, to_status, comment
+        FROM movie_reviews
+        WHERE movie_id = $1
+        ORDER BY created_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []*MovieReview{}
+
+	for rows.Next() {
+		var review MovieReview
+
+		err := rows.Scan(
+			&review.ID,
+			&review.CreatedAt,
+			&review.MovieID,
+			&review.UserID,
+			&review.Action,
+			&review.FromStatus,
+			&review.ToStatus,
+			&review.Comment,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
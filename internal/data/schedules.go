@@ -0,0 +1,207 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	validator "greenlight.lazarmrkic.com/internal"
+	"time"
+)
+
+// "Published()" provjerava da li je film vidljiv korisnicima koji samo čitaju katalog u datom trenutku
+// isti uslov koristi "moviePublishedCondition" unutar SQL upita
+func (movie *Movie) Published(now time.Time) bool {
+	if movie.Status != MovieStatusPublished {
+		return false
+	}
+	if movie.PublishAt != nil && movie.PublishAt.After(now) {
+		return false
+	}
+	if movie.UnpublishAt != nil && !movie.UnpublishAt.After(now) {
+		return false
+	}
+
+	return true
+}
+
+func ValidateSchedule(v *validator.Validator, movie *Movie) {
+	v.Check(movie.PublishAt == nil || movie.UnpublishAt == nil || movie.PublishAt.Before(*movie.UnpublishAt), "unpublish_at", "must be later than publish_at")
+	v.Check(movie.UnpublishAt == nil || movie.UnpublishAt.After(time.Now()), "unpublish_at", "must be in the future")
+}
+
+// "UpdateSchedule()" mijenja samo "publish_at" i "unpublish_at" kolone (uz "optimistic locking")
+// okvir je dio reprezentacije filma i određuje njegovu vidljivost, pa se "movie.updated" poruka i događaj za "stream" upisuju u istoj transakciji
+func (m MovieModel) UpdateSchedule(movie *Movie) error {
+	query := `
+        UPDATE movies
+        SET publish_at = $1, unpublish_at = $2, version = version + 1
+        WHERE id = $3 AND version = $4
+        RETURNING version`
+
+	args := []any{movie.PublishAt, movie.UnpublishAt, movie.ID, movie.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = insertOutbox(ctx, tx, OutboxMovieUpdated, movie)
+	if err != nil {
+		return err
+	}
+
+	err = insertMovieEvent(ctx, tx, "movie.updated", movie.ID, movie)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// "NextScheduledAt()" vraća najraniji trenutak u kom neki film treba da promijeni status ("nil" ukoliko takav ne postoji)
+func (m MovieModel) NextScheduledAt() (*time.Time, error) {
+	query := `
+        SELECT min(at) FROM (
+            SELECT min(publish_at) AS at FROM movies WHERE status = 'scheduled'
+            UNION ALL
+            SELECT min(publish_at) FROM movies WHERE status = 'published' AND publish_at > now()
+            UNION ALL
+            SELECT min(unpublish_at) FROM movies WHERE status = 'published' AND unpublish_at IS NOT NULL
+        ) AS schedules`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var at *time.Time
+
+	err := m.DB.QueryRowContext(ctx, query).Scan(&at)
+	if err != nil {
+		return nil, err
+	}
+
+	return at, nil
+}
+
+// prelazi koje izvršava "scheduler" - "publish" objavljuje zakazane filmove, a "unpublish" arhivira filmove kojima je istekao okvir
+// zakazan film bez "publish_at" vremena (vrijeme je uklonjeno nakon odobravanja) se objavljuje odmah
+// "schedule" vraća u "scheduled" objavljen film kome je naknadno postavljen budući "publish_at" ("PUT /v1/movies/:id/schedule"),
+// pa se i za njega "movie.published" događaj šalje tek kada okvir počne
+var scheduledTransitions = []struct {
+	action string
+	from   string
+	to     string
+	due    string
+}{
+	{action: "schedule", from: MovieStatusPublished, to: MovieStatusScheduled, due: "publish_at > now()"},
+	{action: "publish", from: MovieStatusScheduled, to: MovieStatusPublished, due: "publish_at IS NULL OR publish_at <= now()"},
+	{action: "unpublish", from: MovieStatusPublished, to: MovieStatusArchived, due: "unpublish_at <= now()"},
+}
+
+// "ApplySchedules()" izvršava sve prelaze čije je vrijeme došlo i upisuje ih u istoriju (bez korisnika)
+// svaki prelaz je jedan upit, pa istovremeno pokrenute instance aplikacije ne mogu da izvrše isti prelaz dva puta
+// poruke o novom statusu (i događaji za "stream") se upisuju unutar iste transakcije kao i prelaz
+func (m ReviewModel) ApplySchedules() ([]*MovieReview, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reviews := []*MovieReview{}
+
+	for _, transition := range scheduledTransitions {
+		applied, err := m.applySchedule(ctx, transition.action, transition.from, transition.to, transition.due)
+		if err != nil {
+			return nil, err
+		}
+
+		reviews = append(reviews, applied...)
+	}
+
+	return reviews, nil
+}
+
+func (m ReviewModel) applySchedule(ctx context.Context, action, from, to, due string) ([]*MovieReview, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        WITH due AS (
+            UPDATE movies
+            SET status = $2, version = version + 1
+            WHERE status = $1 AND (` + due + `)
+            RETURNING id
+        )
+        INSERT INTO movie_reviews (movie_id, action, from_status, to_status)
+        SELECT id, $3, $1, $2 FROM due
+        RETURNING id, created_at, movie_id, action, from_status, to_status`
+
+	rows, err := tx.QueryContext(ctx, query, from, to, action)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := []*MovieReview{}
+
+	for rows.Next() {
+		var review MovieReview
+
+		err := rows.Scan(&review.ID, &review.CreatedAt, &review.MovieID, &review.Action, &review.FromStatus, &review.ToStatus)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// redovi se čitaju do kraja prije narednih upita, jer jedna konekcija ne može da izvršava više upita istovremeno
+	events := make([]movieEventInput, len(reviews))
+
+	for i, review := range reviews {
+		movie, err := getMovieTx(ctx, tx, review.MovieID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = insertOutbox(ctx, tx, OutboxMovieStatus(review.ToStatus), movie)
+		if err != nil {
+			return nil, err
+		}
+
+		events[i] = movieEventInput{Type: "movie.updated", MovieID: movie.ID, Data: movie}
+	}
+
+	// događaji za "stream" se upisuju na kraju transakcije, jednim upitom (pogledati "insertMovieEvents()")
+	err = insertMovieEvents(ctx, tx, events)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
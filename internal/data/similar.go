@@ -0,0 +1,131 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// film iz liste sličnih filmova, skupa sa ocjenom sličnosti (od 0 do 1)
+type SimilarMovie struct {
+	ID         int64    `json:"id"`
+	Title      string   `json:"title"`
+	Year       int32    `json:"year"`
+	Runtime    Runtime  `json:"runtime"`
+	Genres     []string `json:"genres"`
+	Similarity float64  `json:"similarity"`
+}
+
+// "GetSimilar()" rangira ostale filmove po sličnosti sa zadatim filmom:
+//   - 60% ocjene nosi preklapanje žanrova (Jaccard indeks - broj zajedničkih žanrova / broj svih žanrova oba filma)
+//   - 25% blizina godine izlaska (razlika od 20 i više godina daje 0)
+//   - 15% sličnost naslova ("pg_trgm", recimo nastavci istog filma)
+//
+// katalog za sada nema podatke o ekipi ("credits"), pa oni ne utiču na ocjenu
+//
+// kandidati su samo filmovi sa bar jednim zajedničkim žanrom, pa upit koristi "movies_genres_idx" indeks
+// "publishedOnly" ograničava rezultate na filmove koji su vidljivi korisnicima koji samo čitaju katalog
+func (m MovieModel) GetSimilar(movie *Movie, publishedOnly bool, filters Filters) ([]*SimilarMovie, Metadata, error) {
+	visibility := "TRUE"
+	if publishedOnly {
+		visibility = moviePublishedCondition
+	}
+
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), id, title, year, runtime, genres, score
+        FROM (
+            SELECT id, title, year, runtime, genres,
+                0.6 * cardinality(ARRAY(SELECT unnest(genres) INTERSECT SELECT unnest($2::text[])))::float8
+                    / cardinality(ARRAY(SELECT unnest(genres) UNION SELECT unnest($2::text[])))
+                + 0.25 * (1 - least(abs(year - $3), 20) / 20.0)
+                + 0.15 * similarity(title, $4) AS score
+            FROM movies
+            WHERE id <> $1 AND genres && $2::text[] AND %s
+        ) AS candidates
+        ORDER BY score DESC, id ASC
+        LIMIT $5 OFFSET $6`, visibility)
+
+	args := []any{movie.ID, pq.Array(movie.Genres), movie.Year, movie.Title, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*SimilarMovie{}
+
+	for rows.Next() {
+		var similar SimilarMovie
+
+		err := rows.Scan(
+			&totalRecords,
+			&similar.ID,
+			&similar.Title,
+			&similar.Year,
+			&similar.Runtime,
+			pq.Array(&similar.Genres),
+			&similar.Similarity,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movies = append(movies, &similar)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
+
+// "VisibleIDs()" vraća skup "id"-eva iz "ids" koji i dalje postoje (i, ukoliko je "publishedOnly" postavljen, vidljivi su u katalogu)
+// koristi se za provjeru keširanih rezultata - ostali filmovi su u međuvremenu mogli da budu arhivirani, obrisani ili spojeni
+func (m MovieModel) VisibleIDs(ids []int64, publishedOnly bool) (map[int64]bool, error) {
+	visibility := "TRUE"
+	if publishedOnly {
+		visibility = moviePublishedCondition
+	}
+
+	query := fmt.Sprintf(`
+        SELECT id
+        FROM movies
+        WHERE id = ANY($1) AND %s`, visibility)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	visible := make(map[int64]bool, len(ids))
+
+	for rows.Next() {
+		var id int64
+
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		visible[id] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return visible, nil
+}
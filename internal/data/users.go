@@ -124,9 +124,16 @@ func (m UserModel) Insert(user *User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	// korisnik i "user.registered" poruka (na osnovu nje se šalje "welcome" email) se upisuju unutar jedne transakcije
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	// "ID", "CreatedAt" i "Version" će biti generisani u bazi
 	// nakon toga, oni će biti vraćeni i učitani upravo u ovaj objekat koji je proslijeđen kao parametar metode
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -136,7 +143,12 @@ func (m UserModel) Insert(user *User) error {
 		}
 	}
 
-	return nil
+	err = insertOutbox(ctx, tx, OutboxUserRegistered, map[string]any{"id": user.ID, "email": user.Email})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // biće vraćen "User" objekat na osnovu "email"-a
@@ -212,6 +224,49 @@ func (m UserModel) Update(user *User) error {
 	return nil
 }
 
+// "Activate()" aktivira korisnika, briše njegove aktivacione tokene i upisuje "user.activated" poruku unutar jedne transakcije
+// kao i "Update()", vraća "ErrEditConflict" ukoliko je korisnik u međuvremenu izmijenjen
+func (m UserModel) Activate(user *User) error {
+	query := `
+        UPDATE users
+        SET activated = true, version = version + 1
+        WHERE id = $1 AND version = $2
+        RETURNING version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, user.ID, user.Version).Scan(&user.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	user.Activated = true
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`, ScopeActivation, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertOutbox(ctx, tx, OutboxUserActivated, map[string]any{"id": user.ID, "name": user.Name})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (m UserModel) GetForToken(tokenScope string, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
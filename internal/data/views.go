@@ -0,0 +1,164 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// podržani vremenski okviri za "trending" filmove i odgovarajući PostgreSQL intervali
+var TrendingWindows = map[string]string{
+	"24h": "24 hours",
+	"7d":  "7 days",
+	"30d": "30 days",
+}
+
+// film iz liste najgledanijih filmova, skupa sa brojem pregleda unutar traženog okvira
+type TrendingMovie struct {
+	ID      int64    `json:"id"`
+	Title   string   `json:"title"`
+	Year    int32    `json:"year"`
+	Runtime Runtime  `json:"runtime"`
+	Genres  []string `json:"genres"`
+	Views   int64    `json:"views"`
+}
+
+// koliko dugo se čuva broj pregleda po satu (najduži "trending" okvir je 30 dana)
+const ViewStatsRetention = 30 * 24 * time.Hour
+
+// jedan pregled filma, zabilježen u trenutku "ViewedAt"
+type MovieView struct {
+	MovieID  int64
+	ViewedAt time.Time
+}
+
+// "ViewModel" služi za interakciju sa "movie_views" i "movie_view_stats" tabelama
+type ViewModel struct {
+	DB *sql.DB
+}
+
+// "Record()" upisuje grupu pojedinačnih pregleda u "movie_views" jednim upitom
+// pregledi obrisanih (ili spojenih) filmova se preskaču
+func (m ViewModel) Record(ctx context.Context, views []MovieView) error {
+	query := `
+        INSERT INTO movie_views (movie_id, viewed_at)
+        SELECT v.movie_id, v.viewed_at
+        FROM unnest($1::bigint[], $2::timestamptz[]) AS v(movie_id, viewed_at)
+        WHERE EXISTS (SELECT 1 FROM movies WHERE movies.id = v.movie_id)`
+
+	movieIDs := make([]int64, len(views))
+	viewedAt := make([]string, len(views))
+
+	for i, view := range views {
+		movieIDs[i] = view.MovieID
+		viewedAt[i] = view.ViewedAt.Format(time.RFC3339Nano)
+	}
+
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(movieIDs), pq.Array(viewedAt))
+	return err
+}
+
+// "Aggregate()" prebacuje pojedinačne preglede u "movie_view_stats" (po satu) i vraća broj obrađenih pregleda
+// redovi se brišu i sabiraju istim upitom, pa se pregled ne može izgubiti niti dva puta uračunati,
+// čak ni kada posao istovremeno radi na više instanci aplikacije
+func (m ViewModel) Aggregate(ctx context.Context) (int64, error) {
+	query := `
+        WITH moved AS (
+            DELETE FROM movie_views
+            RETURNING movie_id, viewed_at
+        ),
+        buckets AS (
+            INSERT INTO movie_view_stats (movie_id, bucket, views)
+            SELECT movie_id, date_trunc('hour', viewed_at), count(*)
+            FROM moved
+            GROUP BY 1, 2
+            ON CONFLICT (movie_id, bucket) DO UPDATE
+            SET views = movie_view_stats.views + EXCLUDED.views
+        )
+        SELECT count(*) FROM moved`
+
+	var count int64
+
+	err := m.DB.QueryRowContext(ctx, query).Scan(&count)
+	return count, err
+}
+
+// "DeleteExpired()" briše broj pregleda stariji od "ViewStatsRetention" i vraća broj obrisanih redova
+func (m ViewModel) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+        DELETE FROM movie_view_stats
+        WHERE bucket < date_trunc('hour', NOW() - make_interval(secs => $1))`
+
+	result, err := m.DB.ExecContext(ctx, query, ViewStatsRetention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// "GetTrending()" vraća filmove sa najviše pregleda unutar okvira "window" (ključ iz "TrendingWindows")
+// okvir obuhvata cijele sate, a pregledi koje pozadinski posao još nije obradio (pogledati "Aggregate()") se ne računaju
+// "publishedOnly" ograničava rezultate na filmove koji su vidljivi korisnicima koji samo čitaju katalog
+func (m ViewModel) GetTrending(window string, publishedOnly bool, filters Filters) ([]*TrendingMovie, Metadata, error) {
+	visibility := "TRUE"
+	if publishedOnly {
+		visibility = moviePublishedCondition
+	}
+
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), id, title, year, runtime, genres, views
+        FROM (
+            SELECT movie_id, sum(views)::bigint AS views
+            FROM movie_view_stats
+            WHERE bucket >= date_trunc('hour', now() - $1::interval)
+            GROUP BY movie_id
+        ) AS stats
+        INNER JOIN movies ON movies.id = stats.movie_id
+        WHERE %s
+        ORDER BY views DESC, id ASC
+        LIMIT $2 OFFSET $3`, visibility)
+
+	args := []any{TrendingWindows[window], filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*TrendingMovie{}
+
+	for rows.Next() {
+		var movie TrendingMovie
+
+		err := rows.Scan(
+			&totalRecords,
+			&movie.ID,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Views,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
@@ -0,0 +1,523 @@
+package data
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/lib/pq"
+	validator "greenlight.lazarmrkic.com/internal"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	// najveći broj pokušaja isporuke jednog događaja
+	WebhookMaxAttempts = 8
+	// broj uzastopnih neuspješnih pokušaja (bilo koje isporuke) nakon kog se pretplata automatski isključuje
+	WebhookMaxFailures = 20
+	// pauza prije drugog pokušaja - svaki naredni pokušaj čeka duplo duže (30s, 1m, 2m, 4m,...), najviše "webhookMaxBackoff"
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+	// koliko dugo je isporuka rezervisana za instancu koja je preuzela (nakon toga je može preuzeti druga instanca)
+	webhookLease = 2 * time.Minute
+)
+
+// koliko dugo se završene ("succeeded" i "failed") isporuke čuvaju u logu, nakon poslednjeg pokušaja
+const WebhookDeliveriesRetention = 30 * 24 * time.Hour
+
+// događaji na koje spoljni sistemi mogu da se pretplate
+var WebhookEvents = []string{"movie.created", "movie.updated", "movie.deleted", "movie.published", "movie.archived", "user.activated"}
+
+// pretplata na događaje
+// "Secret" se vraća samo prilikom kreiranja pretplate
+type Webhook struct {
+	ID                  int64      `json:"id"`
+	URL                 string     `json:"url"`
+	Secret              string     `json:"secret,omitempty"`
+	Events              []string   `json:"events"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	Version             int32      `json:"version"`
+}
+
+// jedna isporuka događaja pretplati (i zapis u logu isporuka)
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	// podaci pretplate koji su potrebni za slanje (ne vraćaju se u logu)
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	u, err := url.Parse(webhook.URL)
+	v.Check(webhook.URL != "", "url", "must be provided")
+	v.Check(len(webhook.URL) <= 2000, "url", "must not be more than 2000 bytes long")
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "must be an absolute http or https URL")
+
+	// prazna tajna se generiše prilikom kreiranja (pogledati "GenerateWebhookSecret()"), a prilikom izmjene ostaje ista
+	if webhook.Secret != "" {
+		v.Check(len(webhook.Secret) >= 16, "secret", "must be at least 16 bytes long")
+		v.Check(len(webhook.Secret) <= 200, "secret", "must not be more than 200 bytes long")
+	}
+
+	v.Check(len(webhook.Events) > 0, "events", "must contain at least 1 event")
+	v.Check(validator.Unique(webhook.Events), "events", "must not contain duplicate values")
+	for _, event := range webhook.Events {
+		v.Check(validator.PermittedValue(event, WebhookEvents...), "events", "contains an unknown event "+strconv.Quote(event))
+	}
+}
+
+// "GenerateWebhookSecret()" vraća nasumičnu tajnu od 32 bajta, enkodiranu kao "hex"
+func GenerateWebhookSecret() (string, error) {
+	randomBytes := make([]byte, 32)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(randomBytes), nil
+}
+
+// "SignWebhook()" vraća potpis tijela isporuke: HMAC-SHA256 nad "<timestamp>.<body>", enkodiran kao "hex"
+// primalac računa isti potpis sa svojom kopijom tajne i poredi ga sa "X-Greenlight-Signature" header-om,
+// a "timestamp" (iz "X-Greenlight-Timestamp" header-a) mu omogućava da odbije stare, ponovo poslate zahtjeve
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// pauza prije narednog pokušaja, nakon "attempts" neuspješnih pokušaja
+func webhookBackoff(attempts int) time.Duration {
+	backoff := float64(webhookBaseBackoff) * math.Pow(2, float64(attempts-1))
+	return time.Duration(min(backoff, float64(webhookMaxBackoff)))
+}
+
+// "WebhookModel" služi za interakciju sa "webhooks" i "webhook_deliveries" tabelama
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+func (m WebhookModel) Insert(webhook *Webhook, userID int64) error {
+	query := `
+        INSERT INTO webhooks (url, secret, events, created_by)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, active, created_at, version`
+
+	args := []any{webhook.URL, webhook.Secret, pq.Array(webhook.Events), userID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.ID, &webhook.Active, &webhook.CreatedAt, &webhook.Version)
+}
+
+// kolone pretplate bez tajne, u redoslijedu koji očekuje "scanWebhook()"
+const webhookColumns = `id, url, events, active, consecutive_failures, disabled_at, created_at, version`
+
+func scanWebhook(webhook *Webhook, dest ...any) []any {
+	return append(dest,
+		&webhook.ID,
+		&webhook.URL,
+		pq.Array(&webhook.Events),
+		&webhook.Active,
+		&webhook.ConsecutiveFailures,
+		&webhook.DisabledAt,
+		&webhook.CreatedAt,
+		&webhook.Version,
+	)
+}
+
+func (m WebhookModel) Get(id int64) (*Webhook, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+        SELECT ` + webhookColumns + `
+        FROM webhooks
+        WHERE id = $1`
+
+	var webhook Webhook
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(scanWebhook(&webhook)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &webhook, nil
+}
+
+func (m WebhookModel) GetAll() ([]*Webhook, error) {
+	query := `
+        SELECT ` + webhookColumns + `
+        FROM webhooks
+        ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		var webhook Webhook
+
+		err := rows.Scan(scanWebhook(&webhook)...)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, &webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// "Update()" mijenja URL, događaje i status pretplate (tajna se mijenja samo ukoliko "Secret" nije prazan)
+// ponovno uključivanje pretplate poništava brojač uzastopnih grešaka
+func (m WebhookModel) Update(webhook *Webhook) error {
+	query := `
+        UPDATE webhooks
+        SET url = $1, events = $2, active = $3, secret = COALESCE(NULLIF($4, ''), secret),
+            consecutive_failures = CASE WHEN $3 AND NOT active THEN 0 ELSE consecutive_failures END,
+            disabled_at = CASE WHEN $3 THEN NULL WHEN active THEN NOW() ELSE disabled_at END,
+            version = version + 1
+        WHERE id = $5 AND version = $6
+        RETURNING consecutive_failures, disabled_at, version`
+
+	args := []any{webhook.URL, pq.Array(webhook.Events), webhook.Active, webhook.Secret, webhook.ID, webhook.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.ConsecutiveFailures, &webhook.DisabledAt, &webhook.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m WebhookModel) Delete(id int64) error {
+	query := `
+        DELETE FROM webhooks
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// "Enqueue()" upisuje isporuku događaja za svaku aktivnu pretplatu na dati tip događaja
+// vraća broj upisanih isporuka
+func (m WebhookModel) Enqueue(event string, payload []byte) (int64, error) {
+	query := `
+        INSERT INTO webhook_deliveries (webhook_id, event, payload)
+        SELECT id, $1, $2
+        FROM webhooks
+        WHERE active AND $1 = ANY(events)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, event, string(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// "EnqueueFor()" upisuje isporuku događaja jednoj pretplati, bez obzira na događaje na koje je pretplaćena
+// koristi se za probnu isporuku ("webhook.ping")
+func (m WebhookModel) EnqueueFor(webhookID int64, event string, payload []byte) (*WebhookDelivery, error) {
+	query := `
+        INSERT INTO webhook_deliveries (webhook_id, event, payload)
+        VALUES ($1, $2, $3)
+        RETURNING id, status, attempts, next_attempt_at, created_at`
+
+	delivery := &WebhookDelivery{WebhookID: webhookID, Event: event, Payload: payload}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, webhookID, event, string(payload)).Scan(
+		&delivery.ID,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// "ClaimDue()" preuzima najviše "limit" isporuka kojima je došlo vrijeme za slanje
+// "FOR UPDATE SKIP LOCKED" i pomjeranje "next_attempt_at" (za "webhookLease") sprječavaju da istu isporuku pošalju dvije instance,
+// a ukoliko se instanca ugasi prije nego što zabilježi rezultat, isporuka se ponovo šalje nakon isteka rezervacije
+func (m WebhookModel) ClaimDue(limit int) ([]*WebhookDelivery, error) {
+	query := `
+        UPDATE webhook_deliveries d
+        SET next_attempt_at = NOW() + make_interval(secs => $2)
+        FROM webhooks w
+        WHERE w.id = d.webhook_id AND d.id IN (
+            SELECT d.id
+            FROM webhook_deliveries d
+            INNER JOIN webhooks w ON w.id = d.webhook_id
+            WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND w.active
+            ORDER BY d.next_attempt_at
+            LIMIT $1
+            FOR UPDATE OF d SKIP LOCKED
+        )
+        RETURNING d.id, d.webhook_id, d.event, d.payload, d.attempts, w.url, w.secret`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, webhookLease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.Event,
+			&delivery.Payload,
+			&delivery.Attempts,
+			&delivery.URL,
+			&delivery.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// "RecordAttempt()" bilježi rezultat jednog pokušaja isporuke (unutar jedne transakcije):
+//   - uspješna isporuka se zatvara, a brojač uzastopnih grešaka pretplate se poništava
+//   - neuspješna isporuka se ponovo zakazuje (eksponencijalni "backoff") ili, nakon "WebhookMaxAttempts" pokušaja, označava kao "failed"
+//   - pretplata se isključuje nakon "WebhookMaxFailures" uzastopnih grešaka
+//
+// "responseStatus" je nula ukoliko odgovor nije ni stigao (recimo, "timeout")
+func (m WebhookModel) RecordAttempt(delivery *WebhookDelivery, responseStatus int, attemptErr error) error {
+	delivery.Attempts++
+	delivery.LastError = ""
+	delivery.Status = DeliverySucceeded
+	delivery.NextAttemptAt = time.Now()
+
+	if responseStatus != 0 {
+		delivery.ResponseStatus = &responseStatus
+	}
+
+	if attemptErr != nil {
+		delivery.LastError = attemptErr.Error()
+		delivery.Status = DeliveryPending
+		delivery.NextAttemptAt = time.Now().Add(webhookBackoff(delivery.Attempts))
+
+		if delivery.Attempts >= WebhookMaxAttempts {
+			delivery.Status = DeliveryFailed
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        UPDATE webhook_deliveries
+        SET status = $1, attempts = $2, next_attempt_at = $3, last_attempt_at = NOW(), response_status = $4, last_error = $5
+        WHERE id = $6`
+
+	args := []any{delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.ResponseStatus, delivery.LastError, delivery.ID}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if attemptErr == nil {
+		query = `
+            UPDATE webhooks
+            SET consecutive_failures = 0
+            WHERE id = $1 AND consecutive_failures > 0`
+
+		_, err = tx.ExecContext(ctx, query, delivery.WebhookID)
+	} else {
+		query = `
+            UPDATE webhooks
+            SET consecutive_failures = consecutive_failures + 1,
+                active = active AND consecutive_failures + 1 < $2,
+                disabled_at = CASE WHEN active AND consecutive_failures + 1 >= $2 THEN NOW() ELSE disabled_at END
+            WHERE id = $1`
+
+		_, err = tx.ExecContext(ctx, query, delivery.WebhookID, WebhookMaxFailures)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// "DeleteExpiredDeliveries()" briše završene isporuke starije od "WebhookDeliveriesRetention" i vraća broj obrisanih isporuka
+// isporuke koje još čekaju ("pending") se ne brišu, bez obzira na starost
+func (m WebhookModel) DeleteExpiredDeliveries() (int64, error) {
+	query := `
+        DELETE FROM webhook_deliveries
+        WHERE status <> 'pending' AND last_attempt_at < NOW() - make_interval(secs => $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, WebhookDeliveriesRetention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// "GetDeliveries()" vraća log isporuka jedne pretplate, od najnovije, uz paginaciju
+// "status" (ukoliko nije prazan) ograničava log na isporuke sa tim statusom
+func (m WebhookModel) GetDeliveries(webhookID int64, status string, filters Filters) ([]*WebhookDelivery, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), id, webhook_id, event, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, created_at
+        FROM webhook_deliveries
+        WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+        ORDER BY id DESC
+        LIMIT $3 OFFSET $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, webhookID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+
+		err := rows.Scan(
+			&totalRecords,
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.Event,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastAttemptAt,
+			&delivery.ResponseStatus,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return deliveries, metadata, nil
+}
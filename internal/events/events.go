@@ -0,0 +1,12 @@
+package events
+
+import "time"
+
+// događaj unutar aplikacije (recimo "movie.published") - prosljeđuje se pretplaćenim spoljnim sistemima i "stream"-u
+// "Data" je opciona reprezentacija resursa u trenutku događaja (recimo, film nakon izmjene)
+type Event struct {
+	Type       string    `json:"type"`
+	MovieID    int64     `json:"movie_id,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data,omitempty"`
+}
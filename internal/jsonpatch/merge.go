@@ -0,0 +1,47 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// "Decode()" pretvara JSON dokument u stablo sastavljeno od "map[string]any", "[]any" i skalarnih vrijednosti
+// brojevi se čuvaju kao "json.Number", kako ne bi došlo do gubitka preciznosti prilikom ponovnog enkodiranja
+func Decode(js []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var doc any
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// "MergePatch()" primjenjuje "JSON Merge Patch" (RFC 7396) nad "target" dokumentom
+// ključevi iz "patch" objekta zamjenjuju postojeće vrijednosti, a ključ sa "null" vrijednošću briše polje iz dokumenta
+// ukoliko "patch" nije JSON objekat, on u potpunosti zamjenjuje "target"
+func MergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any)
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+
+		targetObject[key] = MergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
@@ -0,0 +1,80 @@
+package jsonpatch
+
+import "testing"
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		patch  string
+		want   string
+	}{
+		{
+			name:   "replace value",
+			target: `{"title":"Casablanca","year":1942}`,
+			patch:  `{"title":"Casablanca (1942)"}`,
+			want:   `{"title":"Casablanca (1942)","year":1942}`,
+		},
+		{
+			name:   "add key",
+			target: `{"title":"Casablanca"}`,
+			patch:  `{"runtime":"102 mins"}`,
+			want:   `{"title":"Casablanca","runtime":"102 mins"}`,
+		},
+		{
+			name:   "null deletes key",
+			target: `{"title":"Casablanca","runtime":"102 mins"}`,
+			patch:  `{"runtime":null}`,
+			want:   `{"title":"Casablanca"}`,
+		},
+		{
+			name:   "null for a missing key",
+			target: `{"title":"Casablanca"}`,
+			patch:  `{"runtime":null}`,
+			want:   `{"title":"Casablanca"}`,
+		},
+		{
+			name:   "arrays are replaced",
+			target: `{"genres":["drama","romance"]}`,
+			patch:  `{"genres":["war"]}`,
+			want:   `{"genres":["war"]}`,
+		},
+		{
+			name:   "nested objects are merged",
+			target: `{"external_ids":{"imdb":"tt0034583","tmdb":"289"}}`,
+			patch:  `{"external_ids":{"tmdb":"290","wikidata":"Q132689"}}`,
+			want:   `{"external_ids":{"imdb":"tt0034583","tmdb":"290","wikidata":"Q132689"}}`,
+		},
+		{
+			name:   "null deletes nested key",
+			target: `{"external_ids":{"imdb":"tt0034583","tmdb":"289"}}`,
+			patch:  `{"external_ids":{"tmdb":null}}`,
+			want:   `{"external_ids":{"imdb":"tt0034583"}}`,
+		},
+		{
+			name:   "object replaces scalar",
+			target: `{"external_ids":"none"}`,
+			patch:  `{"external_ids":{"imdb":"tt0034583","tmdb":null}}`,
+			want:   `{"external_ids":{"imdb":"tt0034583"}}`,
+		},
+		{
+			name:   "non-object patch replaces target",
+			target: `{"title":"Casablanca"}`,
+			patch:  `["drama"]`,
+			want:   `["drama"]`,
+		},
+		{
+			name:   "empty patch",
+			target: `{"title":"Casablanca"}`,
+			patch:  `{}`,
+			want:   `{"title":"Casablanca"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MergePatch(mustDecode(t, tt.target), mustDecode(t, tt.patch))
+			assertDocument(t, got, tt.want)
+		})
+	}
+}
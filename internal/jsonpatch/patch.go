@@ -0,0 +1,344 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// "test" operacija nije prošla - vrijednost u dokumentu se ne poklapa sa očekivanom
+	ErrTestFailed = errors.New("test operation failed")
+	// putanja iz operacije ne postoji u dokumentu ili nije validan "JSON Pointer"
+	ErrInvalidPath = errors.New("invalid path")
+)
+
+// jedna operacija iz "JSON Patch" dokumenta (RFC 6902)
+// podržane su sve operacije iz standarda: "add", "remove", "replace", "move", "copy" i "test"
+// "From" se koristi samo kod "move" i "copy" operacija
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// "JSON Patch" dokument je niz operacija koje se primjenjuju redom
+type Patch []Operation
+
+// "Apply()" primjenjuje sve operacije nad kopijom dokumenta i vraća izmijenjenu kopiju
+// ukoliko bilo koja operacija ne uspije, vraća se greška, a originalni dokument ostaje nepromijenjen
+func (p Patch) Apply(doc any) (any, error) {
+	var err error
+
+	doc = clone(doc)
+
+	for i, op := range p {
+		doc, err = op.apply(doc)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	return doc, nil
+}
+
+func (op Operation) apply(doc any) (any, error) {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		// "JSON null" se dekodira u "json.RawMessage("null")", pa "nil" znači da "value" ključ nije poslat
+		if op.Value == nil {
+			return nil, errors.New("missing value")
+		}
+
+		value, err := Decode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.Op {
+		case "add":
+			return addValue(doc, tokens, value)
+		case "replace":
+			// prazna putanja se odnosi na čitav dokument
+			if len(tokens) == 0 {
+				return value, nil
+			}
+			return update(doc, tokens, func(parent any, token string) (any, error) {
+				return replace(parent, token, value)
+			})
+		default:
+			current, err := get(doc, tokens)
+			if err != nil {
+				return nil, err
+			}
+			if !equal(current, value) {
+				return nil, ErrTestFailed
+			}
+			return doc, nil
+		}
+	case "remove":
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("%w: cannot remove the root document", ErrInvalidPath)
+		}
+		return update(doc, tokens, remove)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "copy" {
+			return addValue(doc, tokens, clone(value))
+		}
+
+		// vrijednost ne može da se premjesti unutar same sebe (recimo "/genres" u "/genres/0")
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("%w: cannot move %q into one of its children", ErrInvalidPath, op.From)
+		}
+
+		if len(from) == 0 {
+			return addValue(nil, tokens, value)
+		}
+
+		doc, err = update(doc, from, remove)
+		if err != nil {
+			return nil, err
+		}
+
+		return addValue(doc, tokens, value)
+	default:
+		return nil, fmt.Errorf("unsupported operation %q", op.Op)
+	}
+}
+
+// "addValue()" dodaje vrijednost na zadatu putanju (prazna putanja zamjenjuje čitav dokument)
+func addValue(doc any, tokens []string, value any) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return update(doc, tokens, func(parent any, token string) (any, error) {
+		return add(parent, token, value)
+	})
+}
+
+// "clone()" pravi duboku kopiju dokumenta, kako izmjene ne bi uticale na originalne mape i nizove
+func clone(node any) any {
+	switch node := node.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(node))
+		for key, value := range node {
+			c[key] = clone(value)
+		}
+		return c
+	case []any:
+		c := make([]any, len(node))
+		for i, value := range node {
+			c[i] = clone(value)
+		}
+		return c
+	default:
+		return node
+	}
+}
+
+// "JSON Pointer" (RFC 6901) se razbija na pojedinačne tokene
+// "~1" predstavlja "/", a "~0" predstavlja "~" unutar naziva ključa
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("%w: %q must start with \"/\"", ErrInvalidPath, path)
+	}
+
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// "update()" se spušta niz dokument do roditelja posljednjeg tokena i nad njim poziva "fn"
+// pošto "fn" može da vrati novi "slice" (nakon dodavanja ili brisanja elementa), izmijenjena vrijednost se upisuje nazad u roditelja
+func update(node any, tokens []string, fn func(parent any, token string) (any, error)) (any, error) {
+	if len(tokens) == 1 {
+		return fn(node, tokens[0])
+	}
+
+	child, err := get(node, tokens[:1])
+	if err != nil {
+		return nil, err
+	}
+
+	child, err = update(child, tokens[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parent := node.(type) {
+	case map[string]any:
+		parent[tokens[0]] = child
+	case []any:
+		i, _ := arrayIndex(tokens[0], len(parent))
+		parent[i] = child
+	}
+
+	return node, nil
+}
+
+func get(node any, tokens []string) (any, error) {
+	for _, token := range tokens {
+		switch parent := node.(type) {
+		case map[string]any:
+			value, ok := parent[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: key %q not found", ErrInvalidPath, token)
+			}
+			node = value
+		case []any:
+			i, err := arrayIndex(token, len(parent))
+			if err != nil {
+				return nil, err
+			}
+			node = parent[i]
+		default:
+			return nil, fmt.Errorf("%w: %q is not a container", ErrInvalidPath, token)
+		}
+	}
+
+	return node, nil
+}
+
+func add(parent any, token string, value any) (any, error) {
+	switch parent := parent.(type) {
+	case map[string]any:
+		parent[token] = value
+		return parent, nil
+	case []any:
+		// "-" označava kraj niza (recimo "/genres/-")
+		if token == "-" {
+			return append(parent, value), nil
+		}
+
+		i, err := arrayIndex(token, len(parent)+1)
+		if err != nil {
+			return nil, err
+		}
+
+		parent = append(parent, nil)
+		copy(parent[i+1:], parent[i:])
+		parent[i] = value
+		return parent, nil
+	default:
+		return nil, fmt.Errorf("%w: cannot add to a scalar value", ErrInvalidPath)
+	}
+}
+
+func replace(parent any, token string, value any) (any, error) {
+	switch parent := parent.(type) {
+	case map[string]any:
+		if _, ok := parent[token]; !ok {
+			return nil, fmt.Errorf("%w: key %q not found", ErrInvalidPath, token)
+		}
+		parent[token] = value
+		return parent, nil
+	case []any:
+		i, err := arrayIndex(token, len(parent))
+		if err != nil {
+			return nil, err
+		}
+		parent[i] = value
+		return parent, nil
+	default:
+		return nil, fmt.Errorf("%w: cannot replace inside a scalar value", ErrInvalidPath)
+	}
+}
+
+func remove(parent any, token string) (any, error) {
+	switch parent := parent.(type) {
+	case map[string]any:
+		if _, ok := parent[token]; !ok {
+			return nil, fmt.Errorf("%w: key %q not found", ErrInvalidPath, token)
+		}
+		delete(parent, token)
+		return parent, nil
+	case []any:
+		i, err := arrayIndex(token, len(parent))
+		if err != nil {
+			return nil, err
+		}
+		return append(parent[:i], parent[i+1:]...), nil
+	default:
+		return nil, fmt.Errorf("%w: cannot remove from a scalar value", ErrInvalidPath)
+	}
+}
+
+// indeks niza mora biti nenegativan cijeli broj bez vodećih nula, manji od "length"
+func arrayIndex(token string, length int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrInvalidPath, token)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i >= length {
+		return 0, fmt.Errorf("%w: array index %q out of bounds", ErrInvalidPath, token)
+	}
+
+	return i, nil
+}
+
+// poređenje dvije JSON vrijednosti za potrebe "test" operacije
+// brojevi se porede po vrijednosti, pa su "1" i "1.0" jednaki
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		return errA == nil && errB == nil && x == y
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,232 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const testDocument = `{"title":"Casablanca","year":1942,"genres":["drama","romance"],"external_ids":{"imdb":"tt0034583"},"a/b":1,"m~n":2}`
+
+func decodePatch(t *testing.T, js string) Patch {
+	t.Helper()
+
+	var patch Patch
+	if err := json.Unmarshal([]byte(js), &patch); err != nil {
+		t.Fatal(err)
+	}
+
+	return patch
+}
+
+func mustDecode(t *testing.T, js string) any {
+	t.Helper()
+
+	doc, err := Decode([]byte(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return doc
+}
+
+// dokumenti se porede preko JSON-a ("json.Marshal()" sortira ključeve)
+func assertDocument(t *testing.T, got any, want string) {
+	t.Helper()
+
+	gotJS, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantJS, err := json.Marshal(mustDecode(t, want))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(gotJS) != string(wantJS) {
+		t.Errorf("got %s; want %s", gotJS, wantJS)
+	}
+}
+
+func TestPatchApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{
+			name:  "add key",
+			patch: `[{"op":"add","path":"/runtime","value":"102 mins"}]`,
+			want:  `{"title":"Casablanca","year":1942,"runtime":"102 mins","genres":["drama","romance"],"external_ids":{"imdb":"tt0034583"},"a/b":1,"m~n":2}`,
+		},
+		{
+			name:  "add to the end of an array",
+			patch: `[{"op":"add","path":"/genres/-","value":"war"}]`,
+			want:  `{"title":"Casablanca","year":1942,"genres":["drama","romance","war"],"external_ids":{"imdb":"tt0034583"},"a/b":1,"m~n":2}`,
+		},
+		{
+			name:  "add inside an array",
+			patch: `[{"op":"add","path":"/genres/1","value":"war"}]`,
+			want:  `{"title":"Casablanca","year":1942,"genres":["drama","war","romance"],"external_ids":{"imdb":"tt0034583"},"a/b":1,"m~n":2}`,
+		},
+		{
+			name:  "add null value",
+			patch: `[{"op":"add","path":"/external_ids/tmdb","value":null}]`,
+			want:  `{"title":"Casablanca","year":1942,"genres":["drama","romance"],"external_ids":{"imdb":"tt0034583","tmdb":null},"a/b":1,"m~n":2}`,
+		},
+		{
+			name:  "remove key",
+			patch: `[{"op":"remove","path":"/external_ids/imdb"}]`,
+			want:  `{"title":"Casablanca","year":1942,"genres":["drama","romance"],"external_ids":{},"a/b":1,"m~n":2}`,
+		},
+		{
+			name:  "remove array element",
+			patch: `[{"op":"remove","path":"/genres/0"}]`,
+			want:  `{"title":"Casablanca","year":1942,"genres":["romance"],"external_ids":{"imdb":"tt0034583"},"a/b":1,"m~n":2}`,
+		},
+		{
+			name:  "replace",
+			patch: `[{"op":"replace","path":"/title","value":"Casablanca (1942)"},{"op":"replace","path":"/genres/1","value":"war"}]`,
+			want:  `{"title":"Casablanca (1942)","year":1942,"genres":["drama","war"],"external_ids":{"imdb":"tt0034583"},"a/b":1,"m~n":2}`,
+		},
+		{
+			name:  "replace root",
+			patch: `[{"op":"replace","path":"","value":{"title":"Up"}}]`,
+			want:  `{"title":"Up"}`,
+		},
+		{
+			name:  "move key",
+			patch: `[{"op":"move","from":"/external_ids/imdb","path":"/external_ids/tmdb"}]`,
+			want:  `{"title":"Casablanca","year":1942,"genres":["drama","romance"],"external_ids":{"tmdb":"tt0034583"},"a/b":1,"m~n":2}`,
+		},
+		{
+			name:  "move array element",
+			patch: `[{"op":"move","from":"/genres/0","path":"/genres/-"}]`,
+			want:  `{"title":"Casablanca","year":1942,"genres":["romance","drama"],"external_ids":{"imdb":"tt0034583"},"a/b":1,"m~n":2}`,
+		},
+		{
+			name:  "copy",
+			patch: `[{"op":"copy","from":"/genres","path":"/tags"},{"op":"add","path":"/tags/-","value":"classic"}]`,
+			want:  `{"title":"Casablanca","year":1942,"genres":["drama","romance"],"tags":["drama","romance","classic"],"external_ids":{"imdb":"tt0034583"},"a/b":1,"m~n":2}`,
+		},
+		{
+			name:  "test",
+			patch: `[{"op":"test","path":"/genres","value":["drama","romance"]},{"op":"test","path":"/external_ids","value":{"imdb":"tt0034583"}}]`,
+			want:  testDocument,
+		},
+		{
+			name:  "test numerically equal numbers",
+			patch: `[{"op":"test","path":"/year","value":1942.0},{"op":"test","path":"/year","value":1.942e3}]`,
+			want:  testDocument,
+		},
+		{
+			name:  "escaped slash",
+			patch: `[{"op":"test","path":"/a~1b","value":1},{"op":"replace","path":"/a~1b","value":10}]`,
+			want:  `{"title":"Casablanca","year":1942,"genres":["drama","romance"],"external_ids":{"imdb":"tt0034583"},"a/b":10,"m~n":2}`,
+		},
+		{
+			name:  "escaped tilde",
+			patch: `[{"op":"test","path":"/m~0n","value":2},{"op":"remove","path":"/m~0n"}]`,
+			want:  `{"title":"Casablanca","year":1942,"genres":["drama","romance"],"external_ids":{"imdb":"tt0034583"},"a/b":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodePatch(t, tt.patch).Apply(mustDecode(t, testDocument))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			assertDocument(t, got, tt.want)
+		})
+	}
+}
+
+func TestPatchApplyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		wantErr error
+	}{
+		{
+			name:    "test failed",
+			patch:   `[{"op":"test","path":"/title","value":"Up"}]`,
+			wantErr: ErrTestFailed,
+		},
+		{
+			name:    "test different numbers",
+			patch:   `[{"op":"test","path":"/year","value":1943}]`,
+			wantErr: ErrTestFailed,
+		},
+		{
+			name:    "array index out of range",
+			patch:   `[{"op":"replace","path":"/genres/2","value":"war"}]`,
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "add past the end of an array",
+			patch:   `[{"op":"add","path":"/genres/3","value":"war"}]`,
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "negative array index",
+			patch:   `[{"op":"remove","path":"/genres/-1"}]`,
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "array index with leading zero",
+			patch:   `[{"op":"remove","path":"/genres/01"}]`,
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "missing key",
+			patch:   `[{"op":"remove","path":"/runtime"}]`,
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "path without leading slash",
+			patch:   `[{"op":"remove","path":"title"}]`,
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "move into a child",
+			patch:   `[{"op":"move","from":"/external_ids","path":"/external_ids/ids"}]`,
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "copy from a missing key",
+			patch:   `[{"op":"copy","from":"/runtime","path":"/length"}]`,
+			wantErr: ErrInvalidPath,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodePatch(t, tt.patch).Apply(mustDecode(t, testDocument))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v; want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPatchApplyLeavesDocumentUnchanged(t *testing.T) {
+	patches := []string{
+		`[{"op":"replace","path":"/title","value":"Up"},{"op":"add","path":"/genres/-","value":"war"},{"op":"test","path":"/year","value":2009}]`,
+		`[{"op":"remove","path":"/external_ids/imdb"},{"op":"move","from":"/genres/0","path":"/genres/5"}]`,
+		`[{"op":"copy","from":"/genres","path":"/tags"},{"op":"unknown","path":"/title"}]`,
+	}
+
+	for _, js := range patches {
+		doc := mustDecode(t, testDocument)
+
+		_, err := decodePatch(t, js).Apply(doc)
+		if err == nil {
+			t.Fatalf("patch %s: expected an error", js)
+		}
+
+		assertDocument(t, doc, testDocument)
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE movies DROP COLUMN IF EXISTS external_key;
\ No newline at end of file
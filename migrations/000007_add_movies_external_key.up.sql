@@ -0,0 +1 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS external_key text UNIQUE;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- normalizovane vrijednosti u "movies.genres" ostaju, jer se originalne vrijednosti ne mogu vratiti
+DELETE FROM permissions WHERE code = 'genres:write';
+
+DROP TABLE IF EXISTS genres;
@@ -0,0 +1,68 @@
+CREATE TABLE IF NOT EXISTS genres (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    slug text NOT NULL UNIQUE,
+    name text NOT NULL,
+    aliases text[] NOT NULL DEFAULT '{}',
+    version integer NOT NULL DEFAULT 1
+);
+
+-- ista pravila kao "data.NormalizeGenre()": mala slova, sve osim slova i cifara postaje "-"
+CREATE FUNCTION genre_slug(value text) RETURNS text AS $$
+    SELECT coalesce(nullif(trim(both '-' from regexp_replace(lower(value), '[^a-z0-9]+', '-', 'g')), ''), lower(trim(value)))
+$$ LANGUAGE SQL IMMUTABLE;
+
+INSERT INTO genres (slug, name, aliases)
+VALUES
+    ('action', 'Action', '{}'),
+    ('adventure', 'Adventure', '{}'),
+    ('animation', 'Animation', '{animated,cartoon}'),
+    ('biography', 'Biography', '{biopic,biographical}'),
+    ('comedy', 'Comedy', '{comedies}'),
+    ('crime', 'Crime', '{}'),
+    ('documentary', 'Documentary', '{doc,docs}'),
+    ('drama', 'Drama', '{}'),
+    ('family', 'Family', '{kids}'),
+    ('fantasy', 'Fantasy', '{}'),
+    ('history', 'History', '{historical}'),
+    ('horror', 'Horror', '{}'),
+    ('music', 'Music', '{}'),
+    ('musical', 'Musical', '{}'),
+    ('mystery', 'Mystery', '{}'),
+    ('romance', 'Romance', '{romantic}'),
+    ('science-fiction', 'Science Fiction', '{sci-fi,scifi,sf}'),
+    ('sport', 'Sport', '{sports}'),
+    ('thriller', 'Thriller', '{}'),
+    ('war', 'War', '{}'),
+    ('western', 'Western', '{}');
+
+-- postojeće vrijednosti koje ne odgovaraju nijednom žanru se dodaju kao novi žanrovi, kako se ništa ne bi izgubilo
+INSERT INTO genres (slug, name)
+SELECT DISTINCT ON (genre_slug(g.value)) genre_slug(g.value), initcap(trim(g.value))
+FROM movies, unnest(movies.genres) AS g(value)
+WHERE NOT EXISTS (
+    SELECT 1 FROM genres
+    WHERE genres.slug = genre_slug(g.value) OR genre_slug(g.value) = ANY(genres.aliases)
+)
+ORDER BY genre_slug(g.value), g.value
+ON CONFLICT (slug) DO NOTHING;
+
+-- svaka vrijednost se zamjenjuje "slug"-om odgovarajućeg žanra (duplikati nastali spajanjem se uklanjaju, redosljed se čuva)
+UPDATE movies
+SET genres = mapped.genres, version = version + 1
+FROM (
+    SELECT movies.id, ARRAY(
+        SELECT genres.slug
+        FROM unnest(movies.genres) WITH ORDINALITY AS g(value, position)
+        INNER JOIN genres ON genres.slug = genre_slug(g.value) OR genre_slug(g.value) = ANY(genres.aliases)
+        GROUP BY genres.slug
+        ORDER BY min(g.position)
+    ) AS genres
+    FROM movies
+) AS mapped
+WHERE movies.id = mapped.id AND movies.genres IS DISTINCT FROM mapped.genres;
+
+DROP FUNCTION genre_slug(text);
+
+INSERT INTO permissions (code)
+VALUES ('genres:write');
@@ -0,0 +1,19 @@
+-- "locale" je BCP 47 oznaka jezika i (opciono) regiona, recimo "sr", "sr-RS" ili "en-US" (čuva se malim slovima)
+CREATE TABLE IF NOT EXISTS movie_titles (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    locale text NOT NULL,
+    title text NOT NULL,
+    PRIMARY KEY (movie_id, locale)
+);
+
+CREATE INDEX IF NOT EXISTS movie_titles_title_idx ON movie_titles USING GIN (to_tsvector('simple', title));
+CREATE INDEX IF NOT EXISTS movie_titles_title_trgm_idx ON movie_titles USING GIN (title gin_trgm_ops);
+
+-- "country" je ISO 3166-1 alpha-2 kod (recimo "RS"), a "certification" oznaka starosne granice u toj zemlji (recimo "PG-13" ili "12")
+CREATE TABLE IF NOT EXISTS movie_releases (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    country text NOT NULL,
+    release_date date NOT NULL,
+    certification text NOT NULL DEFAULT '',
+    PRIMARY KEY (movie_id, country)
+);
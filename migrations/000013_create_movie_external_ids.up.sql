@@ -0,0 +1,9 @@
+-- identifikatori filma u spoljnim katalozima (recimo "imdb" → "tt0111161", "tmdb" → "278")
+-- film ima najviše jedan identifikator po izvoru, a isti identifikator ne može da pripada dvama filmovima
+CREATE TABLE IF NOT EXISTS movie_external_ids (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    source text NOT NULL,
+    external_id text NOT NULL,
+    PRIMARY KEY (movie_id, source),
+    CONSTRAINT movie_external_ids_source_external_id_key UNIQUE (source, external_id)
+);
@@ -0,0 +1,12 @@
+-- evidencija spojenih duplikata
+-- "source_id" više ne postoji u "movies" tabeli, pa se preko ovog reda "stari" ID preusmjerava na "target_id"
+CREATE TABLE IF NOT EXISTS movie_merges (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    source_id bigint NOT NULL UNIQUE,
+    source_title text NOT NULL,
+    target_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    merged_by bigint REFERENCES users ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS movie_merges_target_id_idx ON movie_merges (target_id);
@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code = 'movies:publish';
+
+DROP TABLE IF EXISTS movie_reviews;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS status;
@@ -0,0 +1,23 @@
+-- postojeći filmovi su već javno dostupni, pa dobijaju status "published", a novi filmovi počinju kao "draft"
+ALTER TABLE movies ADD COLUMN status text NOT NULL DEFAULT 'published';
+ALTER TABLE movies ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE movies ADD CONSTRAINT movies_status_check CHECK (status IN ('draft', 'in_review', 'published', 'archived'));
+
+CREATE INDEX IF NOT EXISTS movies_status_idx ON movies (status);
+
+-- istorija prelaza između faza, skupa sa komentarima recenzenata
+CREATE TABLE IF NOT EXISTS movie_reviews (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    action text NOT NULL,
+    from_status text NOT NULL,
+    to_status text NOT NULL,
+    comment text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS movie_reviews_movie_id_idx ON movie_reviews (movie_id);
+
+INSERT INTO permissions (code)
+VALUES ('movies:publish');
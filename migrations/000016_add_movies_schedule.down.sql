@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS movies_unpublish_at_idx;
+DROP INDEX IF EXISTS movies_publish_at_idx;
+
+UPDATE movies SET status = 'in_review' WHERE status = 'scheduled';
+ALTER TABLE movies DROP CONSTRAINT movies_status_check;
+ALTER TABLE movies ADD CONSTRAINT movies_status_check CHECK (status IN ('draft', 'in_review', 'published', 'archived'));
+
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_schedule_check;
+ALTER TABLE movies DROP COLUMN IF EXISTS unpublish_at;
+ALTER TABLE movies DROP COLUMN IF EXISTS publish_at;
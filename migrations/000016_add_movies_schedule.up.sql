@@ -0,0 +1,11 @@
+ALTER TABLE movies ADD COLUMN publish_at timestamp(0) with time zone;
+ALTER TABLE movies ADD COLUMN unpublish_at timestamp(0) with time zone;
+ALTER TABLE movies ADD CONSTRAINT movies_schedule_check CHECK (publish_at IS NULL OR unpublish_at IS NULL OR publish_at < unpublish_at);
+
+-- odobren film sa budućim "publish_at" vremenom čeka objavu u "scheduled" statusu
+ALTER TABLE movies DROP CONSTRAINT movies_status_check;
+ALTER TABLE movies ADD CONSTRAINT movies_status_check CHECK (status IN ('draft', 'in_review', 'scheduled', 'published', 'archived'));
+
+-- "scheduler" traži samo filmove koji čekaju na prelaz
+CREATE INDEX IF NOT EXISTS movies_publish_at_idx ON movies (publish_at) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS movies_unpublish_at_idx ON movies (unpublish_at) WHERE status = 'published' AND unpublish_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS movie_similarities;
+DROP TABLE IF EXISTS watched_movies;
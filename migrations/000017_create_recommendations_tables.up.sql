@@ -0,0 +1,17 @@
+-- filmovi koje je korisnik označio kao odgledane (ne preporučuju mu se)
+CREATE TABLE IF NOT EXISTS watched_movies (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    watched_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, movie_id)
+);
+
+-- unaprijed izračunata sličnost između filmova na osnovu ocjena (popunjava je pozadinski posao)
+-- "support" je broj korisnika koji su ocijenili oba filma
+CREATE TABLE IF NOT EXISTS movie_similarities (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    similar_movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    score double precision NOT NULL,
+    support integer NOT NULL,
+    PRIMARY KEY (movie_id, similar_movie_id)
+);
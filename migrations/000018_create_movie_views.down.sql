@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS movie_view_stats;
+DROP TABLE IF EXISTS movie_views;
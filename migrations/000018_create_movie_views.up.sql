@@ -0,0 +1,18 @@
+-- pojedinačni pregledi filmova ("GET /v1/movies/:id")
+-- aplikacija samo dodaje redove, a pozadinski posao ih prebacuje u "movie_view_stats" i briše
+CREATE TABLE IF NOT EXISTS movie_views (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    viewed_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- broj pregleda po filmu i satu
+CREATE TABLE IF NOT EXISTS movie_view_stats (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    bucket timestamp(0) with time zone NOT NULL,
+    views bigint NOT NULL,
+    PRIMARY KEY (movie_id, bucket)
+);
+
+-- "trending" upit sabira preglede unutar vremenskog okvira za sve filmove
+CREATE INDEX IF NOT EXISTS movie_view_stats_bucket_idx ON movie_view_stats (bucket);
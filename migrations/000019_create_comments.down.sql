@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code = 'comments:moderate';
+
+DROP TABLE IF EXISTS comment_reports;
+DROP TABLE IF EXISTS comment_revisions;
+DROP TABLE IF EXISTS comments;
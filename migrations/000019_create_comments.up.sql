@@ -0,0 +1,47 @@
+-- komentari na filmove, organizovani u niti ("parent_id" je komentar na koji se odgovara)
+-- brisanje je "soft delete" ("deleted_at"), kako bi odgovori ostali na svom mjestu unutar niti
+CREATE TABLE IF NOT EXISTS comments (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    parent_id bigint REFERENCES comments ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    body text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    edited_at timestamp(0) with time zone,
+    deleted_at timestamp(0) with time zone,
+    deleted_by bigint REFERENCES users ON DELETE SET NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+-- niti jednog filma (komentari bez "parent_id") i odgovori unutar niti
+CREATE INDEX IF NOT EXISTS comments_movie_id_idx ON comments (movie_id, created_at) WHERE parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS comments_parent_id_idx ON comments (parent_id);
+
+-- prethodne verzije teksta komentara (red se upisuje prilikom svake izmjene)
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id bigserial PRIMARY KEY,
+    comment_id bigint NOT NULL REFERENCES comments ON DELETE CASCADE,
+    body text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL,
+    replaced_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS comment_revisions_comment_id_idx ON comment_revisions (comment_id);
+
+-- prijave komentara - svaki korisnik može da prijavi jedan komentar samo jednom
+-- prijava je otvorena dok je moderator ne razriješi ("resolved_at")
+CREATE TABLE IF NOT EXISTS comment_reports (
+    comment_id bigint NOT NULL REFERENCES comments ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    reason text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    resolved_at timestamp(0) with time zone,
+    resolved_by bigint REFERENCES users ON DELETE SET NULL,
+    resolution text,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS comment_reports_open_idx ON comment_reports (comment_id) WHERE resolved_at IS NULL;
+
+INSERT INTO permissions (code)
+VALUES ('comments:moderate');
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS activities;
+DROP TABLE IF EXISTS follows;
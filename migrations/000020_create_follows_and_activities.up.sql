@@ -0,0 +1,24 @@
+-- korisnik "follower_id" prati korisnika "followee_id"
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    followee_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS follows_followee_id_idx ON follows (followee_id);
+
+-- aktivnosti korisnika (ocjene, komentari,...) - upisuju se unutar iste transakcije kao i sama akcija
+-- "data" sadrži detalje koji zavise od tipa aktivnosti (recimo {"rating": 8})
+CREATE TABLE IF NOT EXISTS activities (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    type text NOT NULL,
+    movie_id bigint REFERENCES movies ON DELETE CASCADE,
+    comment_id bigint REFERENCES comments ON DELETE CASCADE,
+    data jsonb NOT NULL DEFAULT '{}',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS activities_user_id_created_at_idx ON activities (user_id, created_at DESC, id DESC);
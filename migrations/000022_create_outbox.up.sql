@@ -0,0 +1,16 @@
+-- "outbox" - poruke koje se upisuju u istoj transakciji kao i izmjena koja ih je izazvala (recimo, registracija korisnika)
+-- "dispatcher" ih nakon toga isporučuje registrovanim "handler"-ima, pa se poruka ne gubi ukoliko se proces ugasi nakon upisa
+-- poruka je "pending" dok je ne obrade svi "handler"-i ("done") ili dok se ne potroše svi pokušaji ("failed")
+CREATE TABLE IF NOT EXISTS outbox (
+    id bigserial PRIMARY KEY,
+    topic text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_error text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    processed_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE status = 'pending';
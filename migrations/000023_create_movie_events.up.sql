@@ -0,0 +1,12 @@
+-- log izmjena filmova za "GET /v1/events/movies" stream
+-- "id" je ujedno i "id" SSE događaja, pa klijent preko "Last-Event-ID" header-a nastavlja tamo gdje je stao
+-- "movie_id" namjerno nema "REFERENCES" - događaj o brisanju filma ostaje u logu
+CREATE TABLE IF NOT EXISTS movie_events (
+    id bigserial PRIMARY KEY,
+    type text NOT NULL,
+    movie_id bigint NOT NULL,
+    data jsonb NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS movie_events_created_at_idx ON movie_events (created_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS outbox_processed_at_idx;
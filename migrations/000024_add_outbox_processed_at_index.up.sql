@@ -0,0 +1,2 @@
+-- obrađene poruke se periodično brišu (pogledati "OutboxModel.DeleteExpired()")
+CREATE INDEX IF NOT EXISTS outbox_processed_at_idx ON outbox (processed_at) WHERE status <> 'pending';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS webhook_deliveries_last_attempt_at_idx;
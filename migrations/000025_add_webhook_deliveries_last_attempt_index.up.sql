@@ -0,0 +1,2 @@
+-- završene isporuke se periodično brišu (pogledati "WebhookModel.DeleteExpiredDeliveries()")
+CREATE INDEX IF NOT EXISTS webhook_deliveries_last_attempt_at_idx ON webhook_deliveries (last_attempt_at) WHERE status <> 'pending';
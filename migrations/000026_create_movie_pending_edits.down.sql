@@ -0,0 +1 @@
+DROP TABLE IF EXISTS movie_pending_edits;
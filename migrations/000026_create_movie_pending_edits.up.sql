@@ -0,0 +1,16 @@
+-- izmjene objavljenih (i zakazanih) filmova koje čekaju odobrenje korisnika sa "movies:publish" permission-om
+-- objavljena verzija filma ostaje u katalogu dok se izmjena ne odobri ili odbije
+-- "external_ids", "titles" i "releases" su "NULL" ukoliko ih izmjena ne mijenja
+CREATE TABLE IF NOT EXISTS movie_pending_edits (
+    movie_id bigint PRIMARY KEY REFERENCES movies ON DELETE CASCADE,
+    title text NOT NULL,
+    year integer NOT NULL,
+    runtime integer NOT NULL,
+    genres text[] NOT NULL,
+    external_key text,
+    external_ids jsonb,
+    titles jsonb,
+    releases jsonb,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);